@@ -0,0 +1,13 @@
+// Command lockcheck reports lock.Locker acquisitions without Unlock on all
+// paths. It can be run standalone or via "go vet -vettool=$(which lockcheck)".
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/singlechecker"
+
+	"github.com/peertechde/lib/analysis/lockcheck"
+)
+
+func main() {
+	singlechecker.Main(lockcheck.Analyzer)
+}
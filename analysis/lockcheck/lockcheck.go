@@ -0,0 +1,282 @@
+// Package lockcheck defines an Analyzer that reports Lock and TryLock calls on
+// a lock.Locker which aren't followed by an Unlock on every path through the
+// calling function.
+//
+// A path doesn't need an Unlock if it's only taken when the acquisition failed,
+// that is when the error returned by Lock or TryLock was checked against nil.
+// Calls which intentionally return with the lock held can be annotated with a
+// "//lockcheck:ignore" comment on the same line.
+package lockcheck
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/ctrlflow"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/cfg"
+	"golang.org/x/tools/go/types/typeutil"
+)
+
+const (
+	lockPackage = "github.com/peertechde/lib/lock"
+	lockType    = "Locker"
+
+	ignoreDirective = "//lockcheck:ignore"
+)
+
+// acquireMethods are the methods of lock.Locker which acquire the lock.
+var acquireMethods = map[string]bool{
+	"Lock":           true,
+	"LockContext":    true,
+	"TryLock":        true,
+	"TryLockContext": true,
+	"TryLockFor":     true,
+	"RLock":          true,
+	"TryRLock":       true,
+	"LockRange":      true,
+	"TryLockRange":   true,
+}
+
+var Analyzer = &analysis.Analyzer{
+	Name:     "lockcheck",
+	Doc:      "report lock.Locker acquisitions without Unlock on all paths",
+	Run:      run,
+	Requires: []*analysis.Analyzer{inspect.Analyzer, ctrlflow.Analyzer},
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	cfgs := pass.ResultOf[ctrlflow.Analyzer].(*ctrlflow.CFGs)
+
+	filter := []ast.Node{(*ast.FuncDecl)(nil), (*ast.FuncLit)(nil)}
+	inspect.Preorder(filter, func(n ast.Node) {
+		var g *cfg.CFG
+		switch fn := n.(type) {
+		case *ast.FuncDecl:
+			g = cfgs.FuncDecl(fn)
+		case *ast.FuncLit:
+			g = cfgs.FuncLit(fn)
+		}
+		if g == nil {
+			return
+		}
+		checkFunc(pass, g)
+	})
+	return nil, nil
+}
+
+// checkFunc reports acquisitions in g which reach a return without Unlock.
+func checkFunc(pass *analysis.Pass, g *cfg.CFG) {
+	for _, b := range g.Blocks {
+		if !b.Live {
+			continue
+		}
+		for i, node := range b.Nodes {
+			call := findAcquire(pass, node)
+			if call == nil || ignored(pass, call) {
+				continue
+			}
+			recv := types.ExprString(call.Fun.(*ast.SelectorExpr).X)
+			errVar := resultVar(pass, node, call)
+			c := &checker{pass: pass, recv: recv, errVar: errVar, seen: make(map[*cfg.Block]bool)}
+			if c.leaks(b, i+1) {
+				pass.Reportf(call.Pos(), "%s.%s() is not followed by %s.Unlock() on all paths",
+					recv, call.Fun.(*ast.SelectorExpr).Sel.Name, recv)
+			}
+		}
+	}
+}
+
+type checker struct {
+	pass   *analysis.Pass
+	recv   string
+	errVar types.Object
+	seen   map[*cfg.Block]bool
+}
+
+// leaks reports whether a path starting at the node with index start in b
+// returns without unlocking.
+func (c *checker) leaks(b *cfg.Block, start int) bool {
+	for _, node := range b.Nodes[start:] {
+		if c.unlocks(node) {
+			return false
+		}
+	}
+	if len(b.Succs) == 0 {
+		return !c.noReturn(b)
+	}
+	succs := b.Succs
+	if len(succs) == 2 && len(b.Nodes) > 0 {
+		// skip the branch which is only taken if the acquisition failed
+		switch c.failed(b.Nodes[len(b.Nodes)-1]) {
+		case token.NEQ:
+			succs = succs[1:]
+		case token.EQL:
+			succs = succs[:1]
+		}
+	}
+	for _, succ := range succs {
+		if c.seen[succ] {
+			continue
+		}
+		c.seen[succ] = true
+		if c.leaks(succ, 0) {
+			return true
+		}
+	}
+	return false
+}
+
+// unlocks reports whether node calls or defers Unlock on the receiver.
+func (c *checker) unlocks(node ast.Node) bool {
+	found := false
+	ast.Inspect(node, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || found {
+			return !found
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if ok && sel.Sel.Name == "Unlock" && types.ExprString(sel.X) == c.recv {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// failed returns token.NEQ if cond is "err != nil" and token.EQL if cond is
+// "err == nil" for the error returned by the acquisition.
+func (c *checker) failed(cond ast.Node) token.Token {
+	if c.errVar == nil {
+		return token.ILLEGAL
+	}
+	expr, ok := cond.(*ast.BinaryExpr)
+	if !ok || (expr.Op != token.NEQ && expr.Op != token.EQL) {
+		return token.ILLEGAL
+	}
+	x, y := expr.X, expr.Y
+	if isNil(c.pass, x) {
+		x, y = y, x
+	}
+	id, ok := x.(*ast.Ident)
+	if !ok || !isNil(c.pass, y) || c.pass.TypesInfo.Uses[id] != c.errVar {
+		return token.ILLEGAL
+	}
+	return expr.Op
+}
+
+// noReturn reports whether the exit block b ends with a call that doesn't
+// return, like panic or os.Exit.
+func (c *checker) noReturn(b *cfg.Block) bool {
+	if len(b.Nodes) == 0 {
+		return false
+	}
+	stmt, ok := b.Nodes[len(b.Nodes)-1].(*ast.ExprStmt)
+	if !ok {
+		return false
+	}
+	call, ok := stmt.X.(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	switch fn := typeutil.Callee(c.pass.TypesInfo, call).(type) {
+	case *types.Builtin:
+		return fn.Name() == "panic"
+	case *types.Func:
+		if fn.Pkg() == nil {
+			return false
+		}
+		switch fn.Pkg().Path() + "." + fn.Name() {
+		case "os.Exit", "log.Fatal", "log.Fatalf", "log.Fatalln", "log.Panic", "log.Panicf", "log.Panicln":
+			return true
+		}
+	}
+	return false
+}
+
+// findAcquire returns the acquisition call of node if any.
+func findAcquire(pass *analysis.Pass, node ast.Node) *ast.CallExpr {
+	var found *ast.CallExpr
+	ast.Inspect(node, func(n ast.Node) bool {
+		if found != nil {
+			return false
+		}
+		if _, ok := n.(*ast.FuncLit); ok {
+			// function literals are checked on their own
+			return false
+		}
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		sel, ok := call.Fun.(*ast.SelectorExpr)
+		if !ok || !acquireMethods[sel.Sel.Name] {
+			return true
+		}
+		fn, ok := pass.TypesInfo.Uses[sel.Sel].(*types.Func)
+		if ok && isLocker(fn) {
+			found = call
+		}
+		return true
+	})
+	return found
+}
+
+// resultVar returns the variable the error of call is assigned to by node.
+func resultVar(pass *analysis.Pass, node ast.Node, call *ast.CallExpr) types.Object {
+	assign, ok := node.(*ast.AssignStmt)
+	if !ok || len(assign.Lhs) != 1 || len(assign.Rhs) != 1 || assign.Rhs[0] != call {
+		return nil
+	}
+	id, ok := assign.Lhs[0].(*ast.Ident)
+	if !ok {
+		return nil
+	}
+	if obj := pass.TypesInfo.Defs[id]; obj != nil {
+		return obj
+	}
+	return pass.TypesInfo.Uses[id]
+}
+
+func isLocker(fn *types.Func) bool {
+	sig, ok := fn.Type().(*types.Signature)
+	if !ok || sig.Recv() == nil {
+		return false
+	}
+	recv := sig.Recv().Type()
+	if ptr, ok := recv.(*types.Pointer); ok {
+		recv = ptr.Elem()
+	}
+	named, ok := recv.(*types.Named)
+	if !ok || named.Obj().Pkg() == nil {
+		return false
+	}
+	return named.Obj().Pkg().Path() == lockPackage && named.Obj().Name() == lockType
+}
+
+func isNil(pass *analysis.Pass, expr ast.Expr) bool {
+	return pass.TypesInfo.Types[expr].IsNil()
+}
+
+// ignored reports whether call is annotated with the ignore directive.
+func ignored(pass *analysis.Pass, call *ast.CallExpr) bool {
+	pos := pass.Fset.Position(call.Pos())
+	for _, f := range pass.Files {
+		if pass.Fset.Position(f.Pos()).Filename != pos.Filename {
+			continue
+		}
+		for _, group := range f.Comments {
+			for _, comment := range group.List {
+				if strings.HasPrefix(comment.Text, ignoreDirective) && pass.Fset.Position(comment.Pos()).Line == pos.Line {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
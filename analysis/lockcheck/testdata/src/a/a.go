@@ -0,0 +1,112 @@
+package a
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/peertechde/lib/lock"
+)
+
+func deferred(l *lock.Locker) error {
+	if err := l.Lock(); err != nil {
+		return err
+	}
+	defer l.Unlock()
+	return nil
+}
+
+func explicit(l *lock.Locker, cond bool) error {
+	err := l.TryLock()
+	if err != nil {
+		return err
+	}
+	if cond {
+		return l.Unlock()
+	}
+	return l.Unlock()
+}
+
+func succeeded(l *lock.Locker) {
+	if err := l.Lock(); err == nil {
+		l.Unlock()
+	}
+}
+
+func deferredClosure(l *lock.Locker) {
+	l.Lock()
+	defer func() {
+		l.Unlock()
+	}()
+}
+
+func missing(l *lock.Locker) {
+	l.Lock() // want `l.Lock\(\) is not followed by l.Unlock\(\) on all paths`
+}
+
+func missingOnBranch(l *lock.Locker, cond bool) error {
+	if err := l.TryLock(); err != nil { // want `l.TryLock\(\) is not followed by l.Unlock\(\) on all paths`
+		return err
+	}
+	if cond {
+		return nil
+	}
+	return l.Unlock()
+}
+
+func otherReceiver(a, b *lock.Locker) {
+	a.Lock() // want `a.Lock\(\) is not followed by a.Unlock\(\) on all paths`
+	b.Unlock()
+}
+
+func exit(l *lock.Locker, cond bool) {
+	l.Lock()
+	if cond {
+		os.Exit(1)
+	}
+	l.Unlock()
+}
+
+func ignored(l *lock.Locker) {
+	l.Lock() //lockcheck:ignore
+}
+
+func contextDeferred(ctx context.Context, l *lock.Locker) error {
+	if err := l.LockContext(ctx); err != nil {
+		return err
+	}
+	defer l.Unlock()
+	return nil
+}
+
+func contextLeak(ctx context.Context, l *lock.Locker) {
+	l.TryLockContext(ctx) // want `l.TryLockContext\(\) is not followed by l.Unlock\(\) on all paths`
+}
+
+func forLeak(l *lock.Locker) {
+	l.TryLockFor(time.Second) // want `l.TryLockFor\(\) is not followed by l.Unlock\(\) on all paths`
+}
+
+func readDeferred(l *lock.Locker) error {
+	if err := l.RLock(); err != nil {
+		return err
+	}
+	defer l.Unlock()
+	return nil
+}
+
+func readLeak(l *lock.Locker) {
+	l.TryRLock() // want `l.TryRLock\(\) is not followed by l.Unlock\(\) on all paths`
+}
+
+func rangeDeferred(l *lock.Locker) error {
+	if err := l.LockRange(0, 10, true); err != nil {
+		return err
+	}
+	defer l.Unlock()
+	return nil
+}
+
+func rangeLeak(l *lock.Locker) {
+	l.TryLockRange(0, 10, false) // want `l.TryLockRange\(\) is not followed by l.Unlock\(\) on all paths`
+}
@@ -0,0 +1,19 @@
+package lock
+
+import (
+	"context"
+	"time"
+)
+
+type Locker struct{}
+
+func (l *Locker) Lock() error                                             { return nil }
+func (l *Locker) LockContext(ctx context.Context) error                   { return nil }
+func (l *Locker) TryLock() error                                          { return nil }
+func (l *Locker) TryLockContext(ctx context.Context) error                { return nil }
+func (l *Locker) TryLockFor(d time.Duration) error                        { return nil }
+func (l *Locker) RLock() error                                            { return nil }
+func (l *Locker) TryRLock() error                                         { return nil }
+func (l *Locker) LockRange(offset, length int64, exclusive bool) error    { return nil }
+func (l *Locker) TryLockRange(offset, length int64, exclusive bool) error { return nil }
+func (l *Locker) Unlock() error                                           { return nil }
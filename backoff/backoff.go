@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"math"
 	"time"
+
+	"github.com/peertechde/lib/clock"
 )
 
 const (
@@ -17,6 +19,7 @@ func New(min, max time.Duration, factor float64) *Backoff {
 		min:    min,
 		max:    max,
 		factor: factor,
+		clock:  clock.Real,
 	}
 }
 
@@ -31,6 +34,15 @@ type Backoff struct {
 	factor float64
 
 	attempt int
+
+	clock clock.Clock
+}
+
+// WithClock sets the source of time the Backoff waits with and returns the
+// Backoff.
+func (b *Backoff) WithClock(c clock.Clock) *Backoff {
+	b.clock = c
+	return b
 }
 
 // Wait waits for the required time or returns when the context is cancelled.
@@ -38,14 +50,28 @@ func (b *Backoff) Wait(ctx context.Context) error {
 	b.attempt++
 	duration := b.duration(b.attempt)
 
+	c := b.clock
+	if c == nil {
+		c = clock.Real
+	}
+	timer := c.NewTimer(duration)
+	defer timer.Stop()
+
 	select {
 	case <-ctx.Done():
 		return fmt.Errorf("backoff: cancelled via context: %s", ctx.Err())
-	case <-time.After(duration):
+	case <-timer.C():
 	}
 	return nil
 }
 
+// Next returns the backoff time of attempt, min for attempt zero, without
+// waiting. It doesn't change the Backoff and is safe for concurrent use, e.g.
+// as the lock.Backoff of Lockers.
+func (b *Backoff) Next(attempt int) time.Duration {
+	return b.duration(attempt)
+}
+
 func (b *Backoff) duration(attempt int) time.Duration {
 	min := defaultMinimumBackoff
 	if b.min != time.Duration(0) {
@@ -59,5 +85,9 @@ func (b *Backoff) duration(attempt int) time.Duration {
 	if b.max != time.Duration(0) && d > float64(b.max) {
 		d = float64(b.max)
 	}
+	// without max, the delay of late attempts overflows a Duration
+	if d >= math.MaxInt64 {
+		return math.MaxInt64
+	}
 	return time.Duration(d)
 }
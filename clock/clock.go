@@ -0,0 +1,83 @@
+// Package clock provides a substitutable source of time.
+package clock
+
+import (
+	"time"
+)
+
+// Clock tells the time and creates timers.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// Since returns the time elapsed since t.
+	Since(t time.Time) time.Duration
+
+	// NewTimer returns a Timer sending the current time on its channel
+	// after at least d.
+	NewTimer(d time.Duration) Timer
+
+	// AfterFunc returns a Timer calling f in its own goroutine after at
+	// least d. The channel of the returned Timer is nil.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a single event, see time.Timer.
+type Timer interface {
+	// C returns the channel the time is sent on when the Timer fires.
+	C() <-chan time.Time
+
+	// Stop prevents the Timer from firing. It returns false if the Timer
+	// already fired or was stopped.
+	Stop() bool
+
+	// Reset changes the Timer to fire after d. It returns false if the
+	// Timer already fired or was stopped.
+	Reset(d time.Duration) bool
+}
+
+// Real is the Clock of the operating system.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Since(t time.Time) time.Duration {
+	return time.Since(t)
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return realTimer{time.AfterFunc(d, f)}
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
+
+// Monotonic returns a Clock that only advances with the monotonic clock of the
+// operating system. Its times start at the wall clock time of the call and
+// aren't affected by later changes of the wall clock, e.g. by NTP steps.
+func Monotonic() Clock {
+	return monotonicClock{start: time.Now()}
+}
+
+type monotonicClock struct {
+	realClock
+	start time.Time
+}
+
+func (c monotonicClock) Now() time.Time {
+	// the elapsed time is measured with the monotonic clock
+	return c.start.Add(time.Since(c.start))
+}
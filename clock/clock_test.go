@@ -0,0 +1,35 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReal(t *testing.T) {
+	start := Real.Now()
+	timer := Real.NewTimer(10 * time.Millisecond)
+	<-timer.C()
+	if elapsed := Real.Since(start); elapsed < 10*time.Millisecond {
+		t.Fatalf("timer fired after %s", elapsed)
+	}
+	if timer.Stop() {
+		t.Fatal("expected stop of fired timer to return false")
+	}
+
+	fired := make(chan struct{})
+	Real.AfterFunc(time.Millisecond, func() { close(fired) })
+	<-fired
+}
+
+func TestMonotonic(t *testing.T) {
+	c := Monotonic()
+	t1 := c.Now()
+	time.Sleep(time.Millisecond)
+	t2 := c.Now()
+	if !t2.After(t1) {
+		t.Fatalf("expected %s after %s", t2, t1)
+	}
+	if d := t2.Sub(time.Now()); d > time.Second || d < -time.Second {
+		t.Fatalf("expected monotonic time close to wall time, off by %s", d)
+	}
+}
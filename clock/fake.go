@@ -0,0 +1,133 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fake is a Clock that only advances when told to, for testing.
+type Fake struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+var _ Clock = (*Fake)(nil)
+
+// NewFake returns a Fake set to now.
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.now
+}
+
+func (f *Fake) Since(t time.Time) time.Duration {
+	return f.Now().Sub(t)
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	c := make(chan time.Time, 1)
+	t := &fakeTimer{clock: f, c: c}
+	t.fire = func(now time.Time) {
+		select {
+		case c <- now:
+		default:
+		}
+	}
+	f.schedule(t, d)
+	return t
+}
+
+func (f *Fake) AfterFunc(d time.Duration, fn func()) Timer {
+	t := &fakeTimer{clock: f}
+	t.fire = func(time.Time) { go fn() }
+	f.schedule(t, d)
+	return t
+}
+
+// Advance moves the clock forward by d and fires the timers that expired,
+// in order of their deadline.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	f.now = f.now.Add(d)
+	now := f.now
+	var expired, pending []*fakeTimer
+	for _, t := range f.timers {
+		if !t.deadline.After(now) {
+			expired = append(expired, t)
+		} else {
+			pending = append(pending, t)
+		}
+	}
+	f.timers = pending
+	sort.SliceStable(expired, func(i, j int) bool { return expired[i].deadline.Before(expired[j].deadline) })
+	f.mu.Unlock()
+
+	for _, t := range expired {
+		t.fire(now)
+	}
+}
+
+// Timers returns the number of timers which didn't fire yet. Tests use it to
+// wait for the code under test to start waiting.
+func (f *Fake) Timers() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.timers)
+}
+
+func (f *Fake) schedule(t *fakeTimer, d time.Duration) {
+	f.mu.Lock()
+	t.deadline = f.now.Add(d)
+	f.timers = append(f.timers, t)
+	f.mu.Unlock()
+
+	if d <= 0 {
+		f.Advance(0)
+	}
+}
+
+// unschedule removes t and reports whether it was pending.
+func (f *Fake) unschedule(t *fakeTimer) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i, pending := range f.timers {
+		if pending == t {
+			f.timers = append(f.timers[:i], f.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+type fakeTimer struct {
+	clock    *Fake
+	c        chan time.Time
+	fire     func(now time.Time)
+	deadline time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	if t.c == nil {
+		return nil
+	}
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	return t.clock.unschedule(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	pending := t.clock.unschedule(t)
+	t.clock.schedule(t, d)
+	return pending
+}
@@ -0,0 +1,54 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFake(start)
+
+	t1 := c.NewTimer(time.Second)
+	t2 := c.NewTimer(2 * time.Second)
+	fired := make(chan struct{})
+	c.AfterFunc(time.Second, func() { close(fired) })
+	if c.Timers() != 3 {
+		t.Fatalf("expected 3 timers, got %d", c.Timers())
+	}
+
+	c.Advance(500 * time.Millisecond)
+	select {
+	case <-t1.C():
+		t.Fatal("timer fired early")
+	default:
+	}
+
+	c.Advance(500 * time.Millisecond)
+	if now := <-t1.C(); !now.Equal(start.Add(time.Second)) {
+		t.Fatalf("unexpected time %s", now)
+	}
+	<-fired
+	if c.Since(start) != time.Second {
+		t.Fatalf("unexpected elapsed time %s", c.Since(start))
+	}
+
+	if !t2.Stop() {
+		t.Fatal("expected stop of pending timer to return true")
+	}
+	c.Advance(time.Hour)
+	select {
+	case <-t2.C():
+		t.Fatal("stopped timer fired")
+	default:
+	}
+
+	if t2.Reset(time.Second) {
+		t.Fatal("expected reset of stopped timer to return false")
+	}
+	c.Advance(time.Second)
+	<-t2.C()
+	if c.Timers() != 0 {
+		t.Fatalf("expected no timers, got %d", c.Timers())
+	}
+}
@@ -0,0 +1,230 @@
+package clock
+
+import (
+	"sync"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Precise returns a Clock whose timers are backed by timerfd(2) on the
+// monotonic clock. The timers of all precise Clocks are served by a single
+// poller goroutine, they wake up with sub-millisecond precision. An error is
+// returned if timerfd isn't available, e.g. in a sandbox.
+func Precise() (Clock, error) {
+	precise.once.Do(func() {
+		precise.poller, precise.err = newPoller()
+	})
+	if precise.err != nil {
+		return nil, precise.err
+	}
+	return preciseClock{poller: precise.poller}, nil
+}
+
+var precise struct {
+	once   sync.Once
+	poller *poller
+	err    error
+}
+
+type preciseClock struct {
+	realClock
+	poller *poller
+}
+
+func (c preciseClock) NewTimer(d time.Duration) Timer {
+	ch := make(chan time.Time, 1)
+	t := &preciseTimer{poller: c.poller, c: ch, fd: -1}
+	t.fire = func(now time.Time) {
+		select {
+		case ch <- now:
+		default:
+		}
+	}
+	c.poller.arm(t, d)
+	return t
+}
+
+func (c preciseClock) AfterFunc(d time.Duration, f func()) Timer {
+	t := &preciseTimer{poller: c.poller, fd: -1}
+	t.fire = func(time.Time) { go f() }
+	c.poller.arm(t, d)
+	return t
+}
+
+// preciseTimer is armed either with a timerfd or, if creating one failed, with
+// a runtime timer. The fields are guarded by the mutex of the poller.
+type preciseTimer struct {
+	poller  *poller
+	c       chan time.Time
+	fire    func(now time.Time)
+	fd      int
+	runtime *time.Timer
+}
+
+func (t *preciseTimer) C() <-chan time.Time {
+	if t.c == nil {
+		return nil
+	}
+	return t.c
+}
+
+func (t *preciseTimer) Stop() bool {
+	t.poller.mu.Lock()
+	defer t.poller.mu.Unlock()
+
+	return t.poller.disarm(t)
+}
+
+func (t *preciseTimer) Reset(d time.Duration) bool {
+	t.poller.mu.Lock()
+	pending := t.poller.disarm(t)
+	t.poller.mu.Unlock()
+
+	t.poller.arm(t, d)
+	return pending
+}
+
+// poller waits for the expiry of the armed timerfds with epoll.
+type poller struct {
+	epfd int
+
+	mu     sync.Mutex
+	timers map[int]*preciseTimer
+}
+
+func newPoller() (*poller, error) {
+	// probe timerfd, the poller is useless without it
+	fd, err := timerfdCreate()
+	if err != nil {
+		return nil, err
+	}
+	unix.Close(fd)
+
+	epfd, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	p := &poller{epfd: epfd, timers: make(map[int]*preciseTimer)}
+	go p.run()
+	return p, nil
+}
+
+func (p *poller) run() {
+	events := make([]unix.EpollEvent, 64)
+	for {
+		n, err := unix.EpollWait(p.epfd, events, -1)
+		if err != nil {
+			// EINTR, the epoll descriptor is never closed
+			continue
+		}
+		for _, event := range events[:n] {
+			p.expire(int(event.Fd))
+		}
+	}
+}
+
+// expire fires the timer armed with fd, if it expired.
+func (p *poller) expire(fd int) {
+	p.mu.Lock()
+	t := p.timers[fd]
+	if t == nil {
+		p.mu.Unlock()
+		return
+	}
+	// the descriptor may have been reused by a timer which didn't expire yet,
+	// reading it fails with EAGAIN then
+	var expirations [8]byte
+	if _, err := unix.Read(fd, expirations[:]); err != nil {
+		p.mu.Unlock()
+		return
+	}
+	p.disarm(t)
+	p.mu.Unlock()
+
+	t.fire(time.Now())
+}
+
+// arm arms t to expire after d.
+func (p *poller) arm(t *preciseTimer, d time.Duration) {
+	if d <= 0 {
+		// a zero expiry disarms a timerfd
+		d = 1
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	fd, err := timerfdCreate()
+	if err == nil {
+		if err = timerfdSettime(fd, d); err != nil {
+			unix.Close(fd)
+		}
+	}
+	if err == nil {
+		p.timers[fd] = t
+		t.fd = fd
+		event := unix.EpollEvent{Events: unix.EPOLLIN, Fd: int32(fd)}
+		if err = unix.EpollCtl(p.epfd, unix.EPOLL_CTL_ADD, fd, &event); err != nil {
+			p.disarm(t)
+		}
+	}
+	if err != nil {
+		// out of descriptors, fall back to a runtime timer
+		var rt *time.Timer
+		rt = time.AfterFunc(d, func() {
+			p.mu.Lock()
+			fired := t.runtime == rt
+			if fired {
+				t.runtime = nil
+			}
+			p.mu.Unlock()
+
+			if fired {
+				t.fire(time.Now())
+			}
+		})
+		t.runtime = rt
+	}
+}
+
+// disarm disarms t and reports whether it was armed. p.mu must be held.
+func (p *poller) disarm(t *preciseTimer) bool {
+	switch {
+	case t.fd >= 0:
+		unix.EpollCtl(p.epfd, unix.EPOLL_CTL_DEL, t.fd, nil)
+		unix.Close(t.fd)
+		delete(p.timers, t.fd)
+		t.fd = -1
+		return true
+	case t.runtime != nil:
+		t.runtime.Stop()
+		t.runtime = nil
+		return true
+	}
+	return false
+}
+
+// the timerfd syscalls aren't wrapped by the pinned version of x/sys
+
+type itimerspec struct {
+	interval unix.Timespec
+	value    unix.Timespec
+}
+
+func timerfdCreate() (int, error) {
+	fd, _, errno := unix.Syscall(unix.SYS_TIMERFD_CREATE, unix.CLOCK_MONOTONIC, unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+func timerfdSettime(fd int, d time.Duration) error {
+	spec := itimerspec{value: unix.NsecToTimespec(int64(d))}
+	_, _, errno := unix.Syscall6(unix.SYS_TIMERFD_SETTIME, uintptr(fd), 0, uintptr(unsafe.Pointer(&spec)), 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package clock
+
+// Precise returns Real, timerfd(2) is only available on Linux.
+func Precise() (Clock, error) {
+	return Real, nil
+}
@@ -0,0 +1,66 @@
+package clock
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPrecise(t *testing.T) {
+	c, err := Precise()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	timer := c.NewTimer(5 * time.Millisecond)
+	<-timer.C()
+	if elapsed := time.Since(start); elapsed < 5*time.Millisecond {
+		t.Fatalf("timer fired after %s", elapsed)
+	}
+	if timer.Stop() {
+		t.Fatal("expected stop of fired timer to return false")
+	}
+
+	// stopped and reset timers
+	timer = c.NewTimer(time.Hour)
+	if !timer.Stop() {
+		t.Fatal("expected stop of pending timer to return true")
+	}
+	if timer.Reset(time.Millisecond) {
+		t.Fatal("expected reset of stopped timer to return false")
+	}
+	<-timer.C()
+
+	fired := make(chan struct{})
+	c.AfterFunc(0, func() { close(fired) })
+	<-fired
+}
+
+func TestPreciseConcurrent(t *testing.T) {
+	c, err := Precise()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			d := time.Duration(i%10) * time.Millisecond
+			timer := c.NewTimer(d)
+			if i%3 == 0 {
+				timer.Stop()
+				return
+			}
+			select {
+			case <-timer.C():
+			case <-time.After(5 * time.Second):
+				t.Errorf("timer %d didn't fire", i)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
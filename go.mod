@@ -3,7 +3,6 @@ module github.com/peertechde/lib
 go 1.15
 
 require (
-	github.com/pkg/errors v0.9.1
 	github.com/sirupsen/logrus v1.7.0
 	golang.org/x/sys v0.0.0-20191026070338-33540a1f6037
 )
@@ -0,0 +1,56 @@
+package lock
+
+import (
+	"time"
+
+	"github.com/peertechde/lib/backoff"
+)
+
+// Backoff schedules the retries of a Locker waiting for the lock in polling
+// mode, see WithBackoff. Backoffs are shared by the acquisitions of the
+// Locker and must be safe for concurrent use. It's implemented by
+// *backoff.Backoff as well.
+type Backoff interface {
+	// Next returns the delay before the retry following attempt, the
+	// number of failed attempts of the acquisition so far minus one.
+	Next(attempt int) time.Duration
+}
+
+// FixedBackoff returns a Backoff retrying every interval. It's the Backoff of
+// WithRetryInterval.
+func FixedBackoff(interval time.Duration) Backoff {
+	return fixedBackoff(interval)
+}
+
+type fixedBackoff time.Duration
+
+func (b fixedBackoff) Next(int) time.Duration {
+	return time.Duration(b)
+}
+
+// ExponentialBackoff returns a Backoff doubling the delay after every attempt,
+// from initial up to max, see backoff.Backoff. The delay is randomized within
+// its upper half, so that waiters which failed at the same time don't retry in
+// lockstep.
+func ExponentialBackoff(initial, max time.Duration) Backoff {
+	return &exponentialBackoff{backoff.New(initial, max, 2)}
+}
+
+type exponentialBackoff struct {
+	b *backoff.Backoff
+}
+
+func (e *exponentialBackoff) Next(attempt int) time.Duration {
+	d := e.b.Next(attempt)
+	return d/2 + jitter(d-d/2)
+}
+
+// delay returns the delay before the retry following attempt, see Backoff.
+// l.mu must be held.
+func (l *Locker) delay(attempt int) time.Duration {
+	d := l.retryInterval
+	if l.backoff != nil {
+		d = l.backoff.Next(attempt)
+	}
+	return d + jitter(l.jitter)
+}
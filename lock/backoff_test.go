@@ -0,0 +1,96 @@
+package lock
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/peertechde/lib/backoff"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	b := ExponentialBackoff(10*time.Millisecond, 80*time.Millisecond)
+	for attempt, max := range []time.Duration{10, 20, 40, 80, 80, 80} {
+		max *= time.Millisecond
+		for i := 0; i < 100; i++ {
+			if d := b.Next(attempt); d < max/2 || d >= max {
+				t.Fatalf("expected delay of attempt %d within [%s, %s), got %s", attempt, max/2, max, d)
+			}
+		}
+	}
+	if d := FixedBackoff(time.Second).Next(10); d != time.Second {
+		t.Fatalf("expected fixed delay of %s, got %s", time.Second, d)
+	}
+}
+
+func TestExponentialBackoffUnbounded(t *testing.T) {
+	b := ExponentialBackoff(10*time.Millisecond, 0)
+	exp := backoff.New(10*time.Millisecond, 0, 2)
+	var last time.Duration
+	for _, attempt := range []int{10, 30, 62, 63, 64, 100, 1000, math.MaxInt32} {
+		if d := b.Next(attempt); d <= 0 {
+			t.Fatalf("expected positive delay of attempt %d, got %s", attempt, d)
+		}
+		d := exp.Next(attempt)
+		if d < last {
+			t.Fatalf("expected delay of attempt %d of at least %s, got %s", attempt, last, d)
+		}
+		last = d
+	}
+	if last != math.MaxInt64 {
+		t.Fatalf("expected delay to saturate, got %s", last)
+	}
+}
+
+// recordingBackoff records the attempts it's asked for.
+type recordingBackoff struct {
+	mu       sync.Mutex
+	attempts []int
+}
+
+func (b *recordingBackoff) Next(attempt int) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.attempts = append(b.attempts, attempt)
+	return 10 * time.Millisecond
+}
+
+func TestLockBackoff(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	holder := New(file.Name())
+	if err := holder.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	defer holder.Unlock()
+
+	b := &recordingBackoff{}
+	waiter := New(file.Name(), WithPolling(true), WithBackoff(b))
+	if err := waiter.TryLockFor(55 * time.Millisecond); err != ErrLockTimeout {
+		t.Fatalf("expected %v, got %v", ErrLockTimeout, err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.attempts) < 3 {
+		t.Fatalf("expected at least 3 retries, got %v", b.attempts)
+	}
+	for i, attempt := range b.attempts {
+		if attempt != i {
+			t.Fatalf("expected attempts counted from 0, got %v", b.attempts)
+		}
+	}
+
+	waiter.Configure(WithRetryInterval(time.Second))
+	if waiter.backoff != nil {
+		t.Fatal("expected retry interval to replace the backoff")
+	}
+}
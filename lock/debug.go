@@ -0,0 +1,131 @@
+//go:build lockdebug
+// +build lockdebug
+
+package lock
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The lockdebug build tag enables detection of Locker misuse: locking a Locker
+// which is already locked by the same goroutine, unlocking a Locker which isn't locked and unlocking
+// from another goroutine than the one that acquired the lock. Misuse is
+// reported to debugOutput, the offending call proceeds as usual.
+//
+// The state of every Locker ever acquired is retained, debug builds shouldn't
+// be used in production.
+
+var (
+	debugMu     sync.Mutex
+	debugOutput io.Writer = os.Stderr
+	debugStates           = make(map[*Locker]*debugState)
+)
+
+type debugState struct {
+	held      bool
+	goroutine uint64
+	acquired  []byte
+	released  []byte
+}
+
+func debugLock(l *Locker) {
+	debugMu.Lock()
+	defer debugMu.Unlock()
+
+	// other goroutines wait for the Locker to be unlocked, the goroutine
+	// holding it would wait forever
+	state, ok := debugStates[l]
+	if ok && state.held && state.goroutine == debugGoroutine(debugStack()) {
+		debugReport("double lock", l, "previous acquisition", state.acquired,
+			"unlock the Locker before locking it again or use Acquire for independent acquisitions")
+	}
+}
+
+func debugLocked(l *Locker) {
+	debugMu.Lock()
+	defer debugMu.Unlock()
+
+	stack := debugStack()
+	debugStates[l] = &debugState{
+		held:      true,
+		goroutine: debugGoroutine(stack),
+		acquired:  stack,
+	}
+}
+
+func debugUnlock(l *Locker) {
+	debugMu.Lock()
+	defer debugMu.Unlock()
+
+	stack := debugStack()
+	state, ok := debugStates[l]
+	switch {
+	case !ok:
+		debugReport("unlock of unlocked lock", l, "", nil,
+			"only unlock a Locker after Lock or TryLock succeeded")
+		return
+	case !state.held:
+		debugReport("double unlock", l, "previous release", state.released,
+			"make sure every successful Lock or TryLock is paired with exactly one Unlock")
+		return
+	case state.goroutine != debugGoroutine(stack):
+		debugReport("unlock from wrong goroutine", l, "acquisition", state.acquired,
+			"unlock from the goroutine that acquired the lock or use Acquire and pass the Guard")
+	}
+	state.held = false
+	state.released = stack
+}
+
+func debugReport(misuse string, l *Locker, previous string, stack []byte, hint string) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "lock: %s of %q%s\n", misuse, l.path, debugLabels(l))
+	fmt.Fprintf(&buf, "\nhint: %s\n", hint)
+	if previous != "" {
+		fmt.Fprintf(&buf, "\n%s:\n%s\n", previous, stack)
+	}
+	fmt.Fprintf(&buf, "\ncurrent call:\n%s\n", debugStack())
+	debugOutput.Write(buf.Bytes())
+}
+
+// debugLabels formats the labels of l for reports.
+func debugLabels(l *Locker) string {
+	labels := l.Labels()
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return " (" + strings.Join(pairs, ", ") + ")"
+}
+
+func debugStack() []byte {
+	buf := make([]byte, 4096)
+	for {
+		n := runtime.Stack(buf, false)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// debugGoroutine returns the id of the goroutine that captured stack.
+func debugGoroutine(stack []byte) uint64 {
+	stack = bytes.TrimPrefix(stack, []byte("goroutine "))
+	if i := bytes.IndexByte(stack, ' '); i >= 0 {
+		stack = stack[:i]
+	}
+	id, _ := strconv.ParseUint(string(stack), 10, 64)
+	return id
+}
@@ -0,0 +1,8 @@
+//go:build !lockdebug
+// +build !lockdebug
+
+package lock
+
+func debugLock(l *Locker)   {}
+func debugLocked(l *Locker) {}
+func debugUnlock(l *Locker) {}
@@ -0,0 +1,71 @@
+//go:build lockdebug
+// +build lockdebug
+
+package lock
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDebug(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	var buf bytes.Buffer
+	debugOutput = &buf
+	defer func() { debugOutput = os.Stderr }()
+
+	lock := New(file.Name())
+	if err := lock.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("unexpected report: %s", buf.String())
+	}
+
+	// double lock
+	if err := lock.TryLock(); err != ErrLockLocked {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "double lock") {
+		t.Fatalf("expected double lock report, got %q", buf.String())
+	}
+	buf.Reset()
+
+	// labels
+	lock.Configure(WithLabels("job", "compaction"))
+	if err := lock.TryLock(); err != ErrLockLocked {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "(job=compaction)") {
+		t.Fatalf("expected labels in report, got %q", buf.String())
+	}
+	buf.Reset()
+
+	// unlock from wrong goroutine
+	done := make(chan error)
+	go func() {
+		done <- lock.Unlock()
+	}()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "unlock from wrong goroutine") {
+		t.Fatalf("expected wrong goroutine report, got %q", buf.String())
+	}
+	buf.Reset()
+
+	// double unlock
+	lock.Unlock()
+	if !strings.Contains(buf.String(), "double unlock") {
+		t.Fatalf("expected double unlock report, got %q", buf.String())
+	}
+}
@@ -0,0 +1,138 @@
+package lock
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The variables read by FromEnv, each prefixed with the prefix passed to it.
+const (
+	EnvBackend       = "BACKEND"
+	EnvPath          = "PATH"
+	EnvDSN           = "DSN"
+	EnvRetryInterval = "RETRY_INTERVAL"
+	EnvTimeout       = "TIMEOUT"
+
+	// EnvParam prefixes backend specific parameters, e.g. PREFIX_PARAM_LEASE
+	// sets the parameter "lease", see Config.Params.
+	EnvParam = "PARAM_"
+)
+
+// EnvError is returned by FromEnv if environment variables are invalid.
+type EnvError struct {
+	// Invalid maps the names of the invalid variables to the reason.
+	Invalid map[string]string
+}
+
+func (e *EnvError) Error() string {
+	names := make([]string, 0, len(e.Invalid))
+	for name := range e.Invalid {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	reasons := make([]string, 0, len(names))
+	for _, name := range names {
+		reasons = append(reasons, name+": "+e.Invalid[name])
+	}
+	return "lock: invalid environment: " + strings.Join(reasons, "; ")
+}
+
+// FromEnv returns a lock configured by the environment variables starting
+// with prefix, e.g. for the prefix "APP_LOCK":
+//
+//	APP_LOCK_BACKEND=ofd
+//	APP_LOCK_PATH=/var/lock/app
+//	APP_LOCK_RETRY_INTERVAL=100ms
+//	APP_LOCK_TIMEOUT=5s
+//
+// Instead of the backend and path, APP_LOCK_DSN may be set to a URI accepted
+// by OpenURI. The backend defaults to the file lock of the platform: ofd on
+// Linux, flock on Darwin and the BSDs and lockfileex on Windows. Every invalid
+// variable is listed by the returned *EnvError.
+func FromEnv(prefix string) (Interface, error) {
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+	invalid := make(map[string]string)
+	lookup := func(name string) (string, bool) {
+		value, ok := os.LookupEnv(prefix + name)
+		return strings.TrimSpace(value), ok && strings.TrimSpace(value) != ""
+	}
+	duration := func(name string) time.Duration {
+		value, ok := lookup(name)
+		if !ok {
+			return 0
+		}
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			invalid[prefix+name] = fmt.Sprintf("invalid duration %q", value)
+		}
+		return d
+	}
+
+	var (
+		config Config
+		name   = fileBackend
+	)
+	backend, hasBackend := lookup(EnvBackend)
+	path, hasPath := lookup(EnvPath)
+	if dsn, ok := lookup(EnvDSN); ok {
+		if hasBackend || hasPath {
+			invalid[prefix+EnvDSN] = fmt.Sprintf("mutually exclusive with %s%s and %s%s", prefix, EnvBackend, prefix, EnvPath)
+		}
+		u, err := url.Parse(dsn)
+		if err == nil {
+			config, name, err = parseURI(u)
+		}
+		if err != nil {
+			invalid[prefix+EnvDSN] = err.Error()
+		}
+	} else {
+		if hasBackend {
+			name = Backend(backend)
+		}
+		if !hasPath {
+			invalid[prefix+EnvPath] = "not set"
+		}
+		config.Path = path
+	}
+	if config.Params == nil {
+		config.Params = make(map[string]string)
+	}
+	if d := duration(EnvRetryInterval); d != 0 {
+		config.RetryInterval = d
+	}
+	if d := duration(EnvTimeout); d != 0 {
+		config.Timeout = d
+	}
+	for _, env := range os.Environ() {
+		kv := strings.SplitN(env, "=", 2)
+		if len(kv) == 2 && strings.HasPrefix(kv[0], prefix+EnvParam) {
+			if key := strings.ToLower(strings.TrimPrefix(kv[0], prefix+EnvParam)); key != "" {
+				config.Params[key] = kv[1]
+			}
+		}
+	}
+
+	backendsMu.RLock()
+	_, ok := backends[name]
+	backendsMu.RUnlock()
+	variable := prefix + EnvBackend
+	if !hasBackend {
+		variable = prefix + EnvDSN
+	}
+	// the backend of a DSN which failed to parse is unknown, the parse error
+	// is reported instead
+	if _, reported := invalid[variable]; !ok && !reported {
+		invalid[variable] = fmt.Sprintf("unknown backend %q", name)
+	}
+
+	if len(invalid) > 0 {
+		return nil, &EnvError{Invalid: invalid}
+	}
+	return Open(name, config)
+}
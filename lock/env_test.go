@@ -0,0 +1,92 @@
+package lock
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setenv(t *testing.T, env map[string]string) func() {
+	for name, value := range env {
+		if err := os.Setenv(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return func() {
+		for name := range env {
+			os.Unsetenv(name)
+		}
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	defer setenv(t, map[string]string{
+		"TEST_LOCK_PATH":           file.Name(),
+		"TEST_LOCK_RETRY_INTERVAL": "10ms",
+		"TEST_LOCK_TIMEOUT":        "1s",
+	})()
+	l, err := FromEnv("TEST_LOCK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	locker, ok := l.(*Locker)
+	if !ok {
+		t.Fatalf("expected *Locker, got %T", l)
+	}
+	if locker.Path() != file.Name() || locker.RetryInterval() != 10*time.Millisecond {
+		t.Fatalf("unexpected locker %q with retry interval %s", locker.Path(), locker.RetryInterval())
+	}
+
+	defer setenv(t, map[string]string{
+		"TEST_DSN_DSN": "file://" + file.Name() + "?timeout=1s",
+	})()
+	if _, err := FromEnv("TEST_DSN_"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFromEnvInvalid(t *testing.T) {
+	defer setenv(t, map[string]string{
+		"TEST_INVALID_BACKEND":        "unknown",
+		"TEST_INVALID_RETRY_INTERVAL": "often",
+		"TEST_INVALID_TIMEOUT":        "-1s",
+	})()
+	_, err := FromEnv("TEST_INVALID")
+	envErr, ok := err.(*EnvError)
+	if !ok {
+		t.Fatalf("expected *EnvError, got %v", err)
+	}
+	for _, name := range []string{
+		"TEST_INVALID_BACKEND",
+		"TEST_INVALID_PATH",
+		"TEST_INVALID_RETRY_INTERVAL",
+		"TEST_INVALID_TIMEOUT",
+	} {
+		if _, ok := envErr.Invalid[name]; !ok {
+			t.Errorf("expected %s to be invalid: %v", name, err)
+		}
+	}
+}
+
+func TestFromEnvInvalidDSN(t *testing.T) {
+	defer setenv(t, map[string]string{
+		"TEST_INVALID_DSN_DSN": "/var/lock/app",
+	})()
+	_, err := FromEnv("TEST_INVALID_DSN")
+	envErr, ok := err.(*EnvError)
+	if !ok {
+		t.Fatalf("expected *EnvError, got %v", err)
+	}
+	if reason := envErr.Invalid["TEST_INVALID_DSN_DSN"]; !strings.Contains(reason, "has no scheme") {
+		t.Fatalf("expected the parse error of the DSN, got %v", err)
+	}
+}
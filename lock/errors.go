@@ -0,0 +1,116 @@
+package lock
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+var (
+	ErrLockLocked    error = &sentinelError{msg: "lock: lock is locked"}
+	ErrLockTimeout   error = &sentinelError{msg: "lock: lock timed out"}
+	ErrLockCancelled error = &sentinelError{msg: "lock: lock cancelled"}
+	ErrLockDeadlock  error = &sentinelError{msg: "lock: lock would deadlock"}
+	// ErrNotExist is returned if the lock file doesn't exist and isn't
+	// created, see WithCreate. It matches os.ErrNotExist.
+	ErrNotExist error = &sentinelError{msg: "lock: lock file doesn't exist", is: os.ErrNotExist}
+	// ErrIsDirectory is returned if a directory is found where a lock file is
+	// expected, e.g. the lock file within a directory, see WithDirLockName.
+	ErrIsDirectory error = &sentinelError{msg: "lock: lock file is a directory"}
+	// ErrAlreadyLocked is returned if a lock is acquired again by its
+	// holder, which doesn't nest.
+	ErrAlreadyLocked error = &sentinelError{msg: "lock: lock is already held"}
+	// ErrNotLocked is returned if a lock which isn't held is released or
+	// converted. It matches os.ErrInvalid.
+	ErrNotLocked error = &sentinelError{msg: "lock: lock isn't held", is: os.ErrInvalid}
+	// ErrRunning is returned by PIDFile.Acquire if another instance holds the
+	// PID file.
+	ErrRunning error = &sentinelError{msg: "lock: another instance is running"}
+	// ErrSchedulerClosed is the error of acquisitions pending when the
+	// Scheduler was closed.
+	ErrSchedulerClosed error = &sentinelError{msg: "lock: scheduler closed"}
+)
+
+// errReadOnly is returned by exclusive acquisitions of read-only Lockers.
+var errReadOnly error = &sentinelError{msg: "lock: read-only Locker can only be locked shared"}
+
+// errProcRestricted is returned by the Lockers of anonymous shared memory
+// objects in restricted mode, see Shm.Locker.
+var errProcRestricted error = &sentinelError{msg: "lock: descriptors can't be reopened through /proc in restricted mode"}
+
+// sentinelError is the type of the sentinel errors. A sentinel specializing an
+// error of the os package matches it with errors.Is as well.
+type sentinelError struct {
+	msg string
+	is  error
+}
+
+func (e *sentinelError) Error() string {
+	return e.msg
+}
+
+func (e *sentinelError) Is(target error) bool {
+	return e.is != nil && target == e.is
+}
+
+// LockError is returned if the lock file couldn't be opened or locked. It
+// carries the operation, "open", "lock" or "query", and the path of the file;
+// the underlying error is matched with errors.Is and errors.As, e.g. against
+// ErrNotExist or *PermissionError.
+type LockError struct {
+	Op   string
+	Path string
+	Err  error
+}
+
+func (e *LockError) Error() string {
+	var perr *PermissionError
+	if errors.As(e.Err, &perr) {
+		// the PermissionError names the operation and the path already
+		return "lock: " + e.Err.Error()
+	}
+	return "lock: " + e.Op + " " + e.Path + ": " + e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *LockError) Unwrap() error {
+	return e.Err
+}
+
+// openFailed returns the error of a failed attempt to open the lock file at
+// abs.
+func openFailed(abs string, err error) error {
+	if errors.Is(err, syscall.EISDIR) {
+		err = ErrIsDirectory
+	}
+	return &LockError{Op: "open", Path: abs, Err: diagnose("open", abs, err)}
+}
+
+// queryFailed returns the error of a failed attempt to query the lock of the
+// file at abs.
+func queryFailed(abs string, err error) error {
+	return &LockError{Op: "query", Path: abs, Err: diagnose("lock", abs, err)}
+}
+
+// cancelledError is the error of an acquisition cancelled by a context. It
+// matches ErrLockCancelled as well as the error of the context.
+type cancelledError struct {
+	err error
+}
+
+func cancelled(err error) error {
+	return &cancelledError{err: err}
+}
+
+func (e *cancelledError) Error() string {
+	return ErrLockCancelled.Error() + ": " + e.err.Error()
+}
+
+func (e *cancelledError) Is(target error) bool {
+	return target == ErrLockCancelled
+}
+
+// Unwrap returns the error of the context.
+func (e *cancelledError) Unwrap() error {
+	return e.err
+}
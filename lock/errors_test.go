@@ -0,0 +1,42 @@
+package lock
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	missing := filepath.Join(dir, "missing")
+	err = New(missing).TryLock()
+	if !errors.Is(err, ErrNotExist) || !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected %v, got %v", ErrNotExist, err)
+	}
+	var lerr *LockError
+	if !errors.As(err, &lerr) || lerr.Op != "open" || lerr.Path != missing {
+		t.Fatalf("expected *LockError of open %s, got %#v", missing, err)
+	}
+
+	if err := New(dir).Upgrade(); err != ErrNotLocked || !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("expected %v, got %v", ErrNotLocked, err)
+	}
+
+	if runtime.GOOS == "windows" {
+		t.Skip("directories can't be opened for writing on windows")
+	}
+	if err := os.Mkdir(filepath.Join(dir, ".lock"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := New(dir).TryLock(); !errors.Is(err, ErrIsDirectory) {
+		t.Fatalf("expected %v, got %v", ErrIsDirectory, err)
+	}
+}
@@ -0,0 +1,316 @@
+package lock
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// ExecEnv is the environment variable listing the locks exported by
+// ExportForExec as comma separated entries of the descriptor and the escaped
+// path, e.g. "3:%2Fvar%2Flock%2Fapp".
+const ExecEnv = "LOCKLIB_EXEC_FDS"
+
+// ExportForExec prepares the Guard to survive an exec of a new program, e.g.
+// with syscall.Exec, and returns the files to keep open until then. The
+// descriptor of the lock is made inheritable and recorded in the ExecEnv
+// variable of the process environment, the new program reconstructs the Guard
+// with AdoptFromExec(os.Environ()).
+//
+// Children started with os/exec inherit the descriptor as well, they share
+// the lock with the process until both released it.
+func (g *Guard) ExportForExec() ([]*os.File, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	file, err := g.inheritable()
+	if err != nil {
+		return nil, err
+	}
+	fd := strconv.FormatUint(uint64(file.Fd()), 10)
+	// an earlier export of the descriptor is replaced
+	var entries []string
+	for _, entry := range strings.Split(os.Getenv(ExecEnv), ",") {
+		if entry != "" && !strings.HasPrefix(entry, fd+":") {
+			entries = append(entries, entry)
+		}
+	}
+	entries = append(entries, fd+":"+url.PathEscape(g.path))
+	if err := os.Setenv(ExecEnv, strings.Join(entries, ",")); err != nil {
+		return nil, fmt.Errorf("set environment failed: %w", err)
+	}
+	return []*os.File{file}, nil
+}
+
+// Env returns the environment variable which makes the lock recognizable to
+// child processes, e.g. LOCKLIB_FD_VAR_LOCK_APP=3 for /var/lock/app. The
+// descriptor of the lock is made inheritable, a child started with the
+// variable in its environment finds the held lock with Inherited instead of
+// deadlocking on it.
+func (g *Guard) Env() ([]string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	file, err := g.inheritable()
+	if err != nil {
+		return nil, err
+	}
+	return []string{envFD(g.path) + "=" + strconv.FormatUint(uint64(file.Fd()), 10)}, nil
+}
+
+// Inherited returns the Guard of the lock of the file at path if it was
+// inherited from a parent which passed the variable returned by Guard.Env. It
+// returns nil if the lock wasn't inherited. Releasing the returned Guard only
+// closes the descriptor of the child, the lock stays held until the parent
+// released it as well.
+func Inherited(path string) (*Guard, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("absolute represenation of path failed: %w", err)
+	}
+	value := os.Getenv(envFD(abs))
+	if value == "" {
+		return nil, nil
+	}
+	fd, err := strconv.ParseUint(value, 10, 0)
+	if err != nil {
+		return nil, fmt.Errorf("malformed %s: %w", envFD(abs), err)
+	}
+	return ReattachFD(uintptr(fd), abs)
+}
+
+// EnvFDPrefix prefixes the environment variables returned by Guard.Env.
+const EnvFDPrefix = "LOCKLIB_FD_"
+
+// envFD returns the name of the variable advertising the lock of the file at
+// the absolute path.
+func envFD(path string) string {
+	name := []byte(strings.ToUpper(strings.TrimPrefix(path, string(os.PathSeparator))))
+	for i, c := range name {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			name[i] = '_'
+		}
+	}
+	return EnvFDPrefix + string(name)
+}
+
+// inheritable clears close-on-exec of the lock descriptor and returns its
+// file. g.mu must be held.
+func (g *Guard) inheritable() (*os.File, error) {
+	if g.held == nil {
+		return nil, ErrNotLocked
+	}
+	file := g.held.file
+	if _, err := unix.FcntlInt(file.Fd(), unix.F_SETFD, 0); err != nil {
+		return nil, fmt.Errorf("clear close-on-exec failed: %w", err)
+	}
+	return file, nil
+}
+
+// AdoptFromExec reconstructs the Guards exported by ExportForExec before the
+// exec of the current program. env is the environment of the program, usually
+// os.Environ(). Every descriptor is verified to still hold the lock of its
+// file; if adopting one of the Guards fails, the Guards adopted so far are
+// released. Once all Guards are adopted, ExecEnv is removed from the process
+// environment, so that exports for a further exec don't list them twice.
+// AdoptFromExec returns no Guards if ExecEnv isn't set.
+func AdoptFromExec(env []string) ([]*Guard, error) {
+	var exported string
+	for _, kv := range env {
+		if strings.HasPrefix(kv, ExecEnv+"=") {
+			exported = strings.TrimPrefix(kv, ExecEnv+"=")
+		}
+	}
+	if exported == "" {
+		return nil, nil
+	}
+
+	var guards []*Guard
+	release := func() {
+		for _, g := range guards {
+			g.Release()
+		}
+	}
+	for _, entry := range strings.Split(exported, ",") {
+		i := strings.IndexByte(entry, ':')
+		if i < 0 {
+			release()
+			return nil, fmt.Errorf("malformed entry %q", entry)
+		}
+		fd, err := strconv.ParseUint(entry[:i], 10, 0)
+		if err != nil {
+			release()
+			return nil, fmt.Errorf("malformed entry %q: %w", entry, err)
+		}
+		path, err := url.PathUnescape(entry[i+1:])
+		if err != nil {
+			release()
+			return nil, fmt.Errorf("malformed entry %q: %w", entry, err)
+		}
+		g, err := ReattachFD(uintptr(fd), path)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		guards = append(guards, g)
+	}
+	if err := os.Unsetenv(ExecEnv); err != nil {
+		release()
+		return nil, fmt.Errorf("unset environment failed: %w", err)
+	}
+	return guards, nil
+}
+
+// ReattachFD wraps the inherited descriptor fd holding the lock of the file at
+// path in a Guard, e.g. in workers forked by a daemon that holds the lock. It
+// verifies that fd refers to the file at path and still holds the lock, and
+// marks fd close-on-exec again.
+func ReattachFD(fd uintptr, path string) (*Guard, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("absolute represenation of path failed: %w", err)
+	}
+	h, err := adopt(fd, abs)
+	if err != nil {
+		return nil, fmt.Errorf("reattach %s failed: %w", abs, err)
+	}
+	return New(abs).guard(h), nil
+}
+
+// FromFd returns a Locker holding the lock of the inherited descriptor fd,
+// e.g. passed by a parent holding the lock to a new instance of a daemon
+// through cmd.ExtraFiles, see Locker.Fd. The path of the Locker is the path of
+// the file fd refers to. Like ReattachFD, FromFd verifies that fd holds the
+// lock of the file and marks fd close-on-exec again. The Locker holds the lock
+// in the mode fd holds it, exclusively or shared; while other descriptors hold
+// the lock shared, fd can't be told apart from them and acquires a shared lock
+// if it doesn't hold one.
+//
+// The Locker owns fd, Unlock closes it. The lock is shared with the parent
+// until both closed their descriptor, so the parent hands the lock over by
+// unlocking once the child took it. The Locker can be locked again
+// afterwards, configured by opts. In restricted mode the path of fd can't be
+// resolved, see SetRestricted; FromFile adopts a file by its name instead.
+func FromFd(fd uintptr, opts ...Option) (*Locker, error) {
+	path, err := fdPath(fd, "")
+	if err != nil {
+		return nil, err
+	}
+	h, err := adopt(fd, path)
+	if err != nil {
+		return nil, fmt.Errorf("adopt %s failed: %w", path, err)
+	}
+	return adopted(h, opts)
+}
+
+// FromFile returns a Locker holding the lock of file like FromFd. The Locker
+// owns file, file is closed if FromFile fails. In restricted mode the name of
+// file is taken as its path.
+func FromFile(file *os.File, opts ...Option) (*Locker, error) {
+	path, err := fdPath(file.Fd(), file.Name())
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	mode, err := verifyHeld(file.Fd(), path)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("adopt %s failed: %w", path, err)
+	}
+	return adopted(&handle{fs: OSFileSystem, file: file, path: path, mode: mode}, opts)
+}
+
+// adopted returns a new Locker of the file of h holding h.
+func adopted(h *handle, opts []Option) (*Locker, error) {
+	l := New(h.path, opts...)
+	if err := l.hold(h); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// fdPath returns the path of the file fd refers to. In restricted mode /proc
+// isn't read, the path is the name of the file instead, if fd has one; the
+// callers verify that it refers to fd.
+func fdPath(fd uintptr, name string) (string, error) {
+	if !Restricted() {
+		path, err := os.Readlink("/proc/self/fd/" + strconv.FormatUint(uint64(fd), 10))
+		if err == nil {
+			return path, nil
+		}
+		if !restrict("readlink", err) {
+			return "", fmt.Errorf("resolve descriptor %d failed: %w", fd, err)
+		}
+	}
+	if name == "" {
+		return "", fmt.Errorf("resolve descriptor %d failed: no name in restricted mode", fd)
+	}
+	path, err := filepath.Abs(name)
+	if err != nil {
+		return "", fmt.Errorf("absolute represenation of path failed: %w", err)
+	}
+	return path, nil
+}
+
+// adopt verifies that fd refers to the file at path and holds its lock and
+// returns the handle owning fd.
+func adopt(fd uintptr, path string) (*handle, error) {
+	mode, err := verifyHeld(fd, path)
+	if err != nil {
+		return nil, err
+	}
+	return &handle{fs: OSFileSystem, file: os.NewFile(fd, path), path: path, mode: mode}, nil
+}
+
+// verifyHeld verifies that fd refers to the file at path and holds its lock,
+// marks fd close-on-exec and returns the mode of the lock. The lock is kept in
+// the mode it's held in; a shared lock can't be told apart from the shared
+// locks of other descriptors though, it's acquired through fd if fd doesn't
+// hold it.
+func verifyHeld(fd uintptr, path string) (lockMode, error) {
+	var fdStat, pathStat unix.Stat_t
+	if err := unix.Fstat(int(fd), &fdStat); err != nil {
+		return 0, fmt.Errorf("stat descriptor failed: %w", err)
+	}
+	if err := unix.Stat(path, &pathStat); err != nil {
+		return 0, fmt.Errorf("stat path failed: %w", err)
+	}
+	if fdStat.Dev != pathStat.Dev || fdStat.Ino != pathStat.Ino {
+		return 0, fmt.Errorf("descriptor %d doesn't refer to %s", fd, path)
+	}
+
+	// the lock of fd doesn't conflict with itself, so the lock must be visible
+	// through another open file description
+	probe, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("open failed: %w", err)
+	}
+	defer probe.Close()
+	lk := unix.Flock_t{Type: unix.F_WRLCK, Whence: int16(io.SeekStart)}
+	if err := unix.FcntlFlock(probe.Fd(), F_OFD_GETLK, &lk); err != nil {
+		return 0, fmt.Errorf("query lock failed: %w", err)
+	}
+	if lk.Type == unix.F_UNLCK {
+		return 0, fmt.Errorf("descriptor %d doesn't hold the lock of %s", fd, path)
+	}
+	mode := modeExclusive
+	if lk.Type == unix.F_RDLCK {
+		mode = modeShared
+	}
+	// setting the lock of the type held by fd again changes nothing, it fails
+	// if another descriptor holds the lock exclusively
+	err = unix.FcntlFlock(fd, F_OFD_SETLK, &unix.Flock_t{Type: lockType(mode), Whence: int16(io.SeekStart)})
+	if err != nil {
+		return 0, fmt.Errorf("lock of %s is held by another descriptor than %d", path, fd)
+	}
+	if _, err := unix.FcntlInt(fd, unix.F_SETFD, unix.FD_CLOEXEC); err != nil {
+		return 0, fmt.Errorf("set close-on-exec failed: %w", err)
+	}
+	return mode, nil
+}
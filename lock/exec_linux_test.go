@@ -0,0 +1,534 @@
+package lock
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+const execChildEnv = "LOCK_TEST_EXEC_CHILD"
+
+func TestExportForExec(t *testing.T) {
+	if os.Getenv(execChildEnv) != "" {
+		execChild()
+		return
+	}
+
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	g, err := New(file.Name()).Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Release()
+	if _, err := g.ExportForExec(); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv(ExecEnv)
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestExportForExec$")
+	cmd.Env = append(os.Environ(), execChildEnv+"=1")
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != "adopted" {
+		t.Fatalf("child didn't adopt the lock: %q %v", line, err)
+	}
+
+	// the child keeps the lock after the parent released its descriptor
+	if err := g.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if err := New(file.Name()).TryLock(); err != ErrLockLocked {
+		t.Fatalf("expected lock to be held by child, got %v", err)
+	}
+
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	l := New(file.Name())
+	if err := l.TryLock(); err != nil {
+		t.Fatal(err)
+	}
+	l.Unlock()
+}
+
+// execChild adopts the exported lock and holds it until stdin is closed.
+func execChild() {
+	guards, err := AdoptFromExec(os.Environ())
+	if err != nil || len(guards) != 1 {
+		fmt.Fprintf(os.Stderr, "adopt failed: %d guards, %v\n", len(guards), err)
+		os.Exit(1)
+	}
+	fmt.Println("adopted")
+	ioutil.ReadAll(os.Stdin)
+	guards[0].Release()
+	os.Exit(0)
+}
+
+func TestExportForExecChain(t *testing.T) {
+	switch os.Getenv(execChildEnv) {
+	case "re-exec":
+		execChainChild()
+		return
+	case "chained":
+		execChainedChild()
+		return
+	}
+
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	g, err := New(file.Name()).Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Release()
+	defer os.Unsetenv(ExecEnv)
+	// exporting the Guard again doesn't list its descriptor twice
+	for i := 0; i < 2; i++ {
+		if _, err := g.ExportForExec(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if entries := strings.Split(os.Getenv(ExecEnv), ","); len(entries) != 1 {
+		t.Fatalf("expected a single exported entry, got %v", entries)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestExportForExecChain$")
+	cmd.Env = append(os.Environ(), execChildEnv+"=re-exec")
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// execChainChild adopts the exported lock, exports it again and execs the
+// next program of the chain.
+func execChainChild() {
+	guards, err := AdoptFromExec(os.Environ())
+	if err != nil || len(guards) != 1 {
+		fmt.Fprintf(os.Stderr, "adopt failed: %d guards, %v\n", len(guards), err)
+		os.Exit(1)
+	}
+	if exported := os.Getenv(ExecEnv); exported != "" {
+		fmt.Fprintf(os.Stderr, "expected adopted locks to be unset, got %q\n", exported)
+		os.Exit(1)
+	}
+	if _, err := guards[0].ExportForExec(); err != nil {
+		fmt.Fprintf(os.Stderr, "export failed: %v\n", err)
+		os.Exit(1)
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestExportForExecChain$")
+	cmd.Env = append(os.Environ(), execChildEnv+"=chained")
+	cmd.Stdout, cmd.Stderr = os.Stderr, os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "chained exec failed: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// execChainedChild adopts the lock exported once by each program of the chain.
+func execChainedChild() {
+	if entries := strings.Split(os.Getenv(ExecEnv), ","); len(entries) != 1 {
+		fmt.Fprintf(os.Stderr, "expected a single exported entry, got %v\n", entries)
+		os.Exit(1)
+	}
+	guards, err := AdoptFromExec(os.Environ())
+	if err != nil || len(guards) != 1 {
+		fmt.Fprintf(os.Stderr, "adopt failed: %d guards, %v\n", len(guards), err)
+		os.Exit(1)
+	}
+	guards[0].Release()
+	os.Exit(0)
+}
+
+func TestAdoptFromExecInvalid(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	// the descriptor doesn't hold the lock
+	env := []string{fmt.Sprintf("%s=%d:%s", ExecEnv, file.Fd(), url.PathEscape(file.Name()))}
+	if _, err := AdoptFromExec(env); err == nil {
+		t.Fatal("expected adoption of unlocked descriptor to fail")
+	}
+
+	// another descriptor holds the lock
+	l := New(file.Name())
+	if err := l.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Unlock()
+	if _, err := AdoptFromExec(env); err == nil {
+		t.Fatal("expected adoption of foreign lock to fail")
+	}
+
+	if guards, err := AdoptFromExec(nil); err != nil || guards != nil {
+		t.Fatalf("expected no guards, got %v %v", guards, err)
+	}
+}
+
+func TestReattachFD(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	g, err := New(file.Name()).Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Release()
+
+	// a duplicated descriptor shares the open file description like an
+	// inherited one
+	fd, err := unix.Dup(int(g.File().Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReattachFD(uintptr(fd), file.Name()+".other"); err == nil {
+		t.Fatal("expected reattach with wrong path to fail")
+	}
+	reattached, err := ReattachFD(uintptr(fd), file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if err := New(file.Name()).TryLock(); err != ErrLockLocked {
+		t.Fatalf("expected lock to be held by reattached guard, got %v", err)
+	}
+	if err := reattached.Release(); err != nil {
+		t.Fatal(err)
+	}
+	l := New(file.Name())
+	if err := l.TryLock(); err != nil {
+		t.Fatal(err)
+	}
+	l.Unlock()
+}
+
+func TestInherited(t *testing.T) {
+	if os.Getenv(execChildEnv) != "" {
+		inheritedChild()
+		return
+	}
+
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	if g, err := Inherited(file.Name()); err != nil || g != nil {
+		t.Fatalf("expected no inherited lock, got %v %v", g, err)
+	}
+
+	g, err := New(file.Name()).Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Release()
+	env, err := g.Env()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(env) != 1 || !strings.HasPrefix(env[0], EnvFDPrefix) {
+		t.Fatalf("unexpected environment %v", env)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestInherited$")
+	cmd.Env = append(append(os.Environ(), execChildEnv+"=1", "LOCK_TEST_PATH="+file.Name()), env...)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// inheritedChild finds the inherited lock instead of blocking on it.
+func inheritedChild() {
+	path := os.Getenv("LOCK_TEST_PATH")
+	g, err := Inherited(path)
+	if err != nil || g == nil {
+		fmt.Fprintf(os.Stderr, "inherited failed: %v %v\n", g, err)
+		os.Exit(1)
+	}
+	if err := New(path).TryLock(); err != ErrLockLocked {
+		fmt.Fprintf(os.Stderr, "expected lock to be held, got %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func TestFromFd(t *testing.T) {
+	if os.Getenv(execChildEnv) != "" {
+		fromFdChild()
+		return
+	}
+
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	parent := New(file.Name())
+	if fd := parent.Fd(); fd != ^uintptr(0) {
+		t.Fatalf("expected no descriptor, got %d", fd)
+	}
+	if err := parent.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	defer parent.Unlock()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFromFd$")
+	cmd.Env = append(os.Environ(), execChildEnv+"=1", "LOCK_TEST_PATH="+file.Name())
+	cmd.ExtraFiles = []*os.File{parent.File()}
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != "adopted" {
+		t.Fatalf("child didn't adopt the lock: %q %v", line, err)
+	}
+
+	// the child holds the lock once the parent handed it over
+	if err := parent.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := New(file.Name()).TryLock(); err != ErrLockLocked {
+		t.Fatalf("expected lock to be held by child, got %v", err)
+	}
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	l := New(file.Name())
+	if err := l.TryLock(); err != nil {
+		t.Fatal(err)
+	}
+	l.Unlock()
+}
+
+// fromFdChild takes the inherited lock over, holds it until stdin is closed
+// and locks it again.
+func fromFdChild() {
+	l, err := FromFd(3)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "from fd failed: %v\n", err)
+		os.Exit(1)
+	}
+	if l.Path() != os.Getenv("LOCK_TEST_PATH") || !l.IsLocked() {
+		fmt.Fprintf(os.Stderr, "unexpected Locker of %s\n", l.Path())
+		os.Exit(1)
+	}
+	fmt.Println("adopted")
+	ioutil.ReadAll(os.Stdin)
+	if err := l.Unlock(); err != nil {
+		fmt.Fprintf(os.Stderr, "unlock failed: %v\n", err)
+		os.Exit(1)
+	}
+	if err := l.TryLock(); err != nil {
+		fmt.Fprintf(os.Stderr, "relock failed: %v\n", err)
+		os.Exit(1)
+	}
+	l.Unlock()
+	os.Exit(0)
+}
+
+func TestFromFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	l := New(file.Name())
+	if err := l.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Unlock()
+	if _, err := FromFile(file); err == nil {
+		t.Fatal("expected closed file to be rejected")
+	}
+
+	// a duplicated descriptor shares the open file description like an
+	// inherited one
+	fd, err := unix.Dup(int(l.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	adopted, err := FromFile(os.NewFile(uintptr(fd), "dup"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if adopted.Path() != file.Name() {
+		t.Fatalf("expected path %s, got %s", file.Name(), adopted.Path())
+	}
+	if err := l.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := New(file.Name()).TryLock(); err != ErrLockLocked {
+		t.Fatalf("expected lock to be held by adopted Locker, got %v", err)
+	}
+	if err := adopted.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFromFileRestricted(t *testing.T) {
+	SetRestricted(true)
+	defer SetRestricted(false)
+
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	l := New(file.Name())
+	if err := l.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Unlock()
+	fd, err := unix.Dup(int(l.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FromFd(uintptr(fd)); err == nil {
+		t.Fatal("expected descriptor without name to be rejected in restricted mode")
+	}
+	unix.Close(fd)
+
+	// the name of the file must refer to the descriptor
+	fd, err = unix.Dup(int(l.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FromFile(os.NewFile(uintptr(fd), "dup")); err == nil {
+		t.Fatal("expected file with a foreign name to be rejected")
+	}
+	fd, err = unix.Dup(int(l.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	adopted, err := FromFile(os.NewFile(uintptr(fd), file.Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if adopted.Path() != file.Name() {
+		t.Fatalf("expected path %s, got %s", file.Name(), adopted.Path())
+	}
+	if err := adopted.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFromFileShared(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	// a descriptor which doesn't hold the lock is rejected and closed
+	unlocked, err := os.Open(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FromFile(unlocked); err == nil {
+		t.Fatal("expected unlocked descriptor to be rejected")
+	}
+	if unlocked.Fd() != ^uintptr(0) {
+		t.Fatal("expected rejected file to be closed")
+	}
+
+	l, reader := New(file.Name()), New(file.Name())
+	if err := l.RLock(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Unlock()
+	if err := reader.RLock(); err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Unlock()
+
+	// the shared lock is adopted while another descriptor holds it shared as
+	// well, and isn't upgraded
+	fd, err := unix.Dup(int(l.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	adopted, err := FromFile(os.NewFile(uintptr(fd), "dup"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer adopted.Unlock()
+	if err := l.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := reader.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	other := New(file.Name())
+	if err := other.TryRLock(); err != nil {
+		t.Fatalf("expected adopted lock to stay shared, got %v", err)
+	}
+	other.Unlock()
+	if err := other.TryLock(); err != ErrLockLocked {
+		t.Fatalf("expected lock to be held shared by adopted Locker, got %v", err)
+	}
+	if err := adopted.Upgrade(); err != nil {
+		t.Fatalf("expected adopted shared lock to be upgradable, got %v", err)
+	}
+}
@@ -0,0 +1,22 @@
+package lock
+
+import (
+	"os"
+)
+
+// OSFileSystem is the FileSystem of the operating system. It's used by default.
+var OSFileSystem FileSystem = osFileSystem{}
+
+type osFileSystem struct{}
+
+func (osFileSystem) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (osFileSystem) OpenFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+func (osFileSystem) Close(f *os.File) error {
+	return f.Close()
+}
@@ -0,0 +1,31 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package lock
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// FileSystem provides the file operations performed by a Locker. It allows to
+// substitute the operating system, e.g. to inject faults in tests, see the
+// locktest package.
+type FileSystem interface {
+	// Stat returns the FileInfo of the file at name.
+	Stat(name string) (os.FileInfo, error)
+
+	// OpenFile opens the file at name, see os.OpenFile.
+	OpenFile(name string, flag int, perm os.FileMode) (*os.File, error)
+
+	// Flock applies the lock operation how to f, see flock(2).
+	Flock(f *os.File, how int) error
+
+	// Close closes f.
+	Close(f *os.File) error
+}
+
+func (osFileSystem) Flock(f *os.File, how int) error {
+	return unix.Flock(int(f.Fd()), how)
+}
@@ -0,0 +1,28 @@
+package lock
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// FileSystem provides the file operations performed by a Locker. It allows to
+// substitute the operating system, e.g. to inject faults in tests, see the
+// locktest package.
+type FileSystem interface {
+	// Stat returns the FileInfo of the file at name.
+	Stat(name string) (os.FileInfo, error)
+
+	// OpenFile opens the file at name, see os.OpenFile.
+	OpenFile(name string, flag int, perm os.FileMode) (*os.File, error)
+
+	// Fcntl applies the record locking command cmd to f, see fcntl(2).
+	Fcntl(f *os.File, cmd int, lk *unix.Flock_t) error
+
+	// Close closes f.
+	Close(f *os.File) error
+}
+
+func (osFileSystem) Fcntl(f *os.File, cmd int, lk *unix.Flock_t) error {
+	return unix.FcntlFlock(f.Fd(), cmd, lk)
+}
@@ -0,0 +1,39 @@
+package lock
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// FileSystem provides the file operations performed by a Locker. It allows to
+// substitute the operating system, e.g. to inject faults in tests, see the
+// locktest package.
+type FileSystem interface {
+	// Stat returns the FileInfo of the file at name.
+	Stat(name string) (os.FileInfo, error)
+
+	// OpenFile opens the file at name, see os.OpenFile.
+	OpenFile(name string, flag int, perm os.FileMode) (*os.File, error)
+
+	// LockFileEx locks the region of f with flags, see LockFileEx of the
+	// Windows API. ol specifies the offset of the region and the event
+	// signaled if the operation completes asynchronously, bytesLow and
+	// bytesHigh its length.
+	LockFileEx(f *os.File, flags, bytesLow, bytesHigh uint32, ol *windows.Overlapped) error
+
+	// UnlockFileEx unlocks the region of f, see UnlockFileEx of the Windows
+	// API.
+	UnlockFileEx(f *os.File, bytesLow, bytesHigh uint32, ol *windows.Overlapped) error
+
+	// Close closes f.
+	Close(f *os.File) error
+}
+
+func (osFileSystem) LockFileEx(f *os.File, flags, bytesLow, bytesHigh uint32, ol *windows.Overlapped) error {
+	return windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, bytesLow, bytesHigh, ol)
+}
+
+func (osFileSystem) UnlockFileEx(f *os.File, bytesLow, bytesHigh uint32, ol *windows.Overlapped) error {
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, bytesLow, bytesHigh, ol)
+}
@@ -0,0 +1,256 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/peertechde/lib/clock"
+)
+
+// Guard represents a single acquisition of a lock. The Guard owns the
+// acquisition, only releasing the Guard releases the lock.
+type Guard struct {
+	// the state is referenced by the Locker while the lock is held, the Guard
+	// itself must stay collectable for the finalizer to work
+	*guardState
+}
+
+type guardState struct {
+	locker    *Locker
+	path      string
+	heldSince time.Time
+	logger    logrus.FieldLogger
+	clock     clock.Clock
+
+	mu       sync.Mutex
+	held     *handle
+	done     chan struct{}
+	handoffs []func(deadline time.Time)
+	expiry   clock.Timer
+
+	// if bounded, the Guard is valid for validity since renewed
+	bounded  bool
+	renewed  time.Time
+	validity time.Duration
+}
+
+// Acquire blocks until the lock is acquired or ctx is done. Unlike Lock,
+// Acquire doesn't change the state of the Locker, the acquisition is
+// represented by the returned Guard instead. A Locker can be used to acquire
+// any number of Guards; they exclude each other like Lockers do.
+func (l *Locker) Acquire(ctx context.Context) (*Guard, error) {
+	h, err := l.acquire(ctx, modeExclusive, region{}, true)
+	if err != nil {
+		return nil, err
+	}
+	return l.guard(h), nil
+}
+
+// AcquireAsync acquires like Acquire in the background. The returned channel
+// receives the Guard once the lock is acquired, or the error if it couldn't be
+// acquired before ctx was done, so that callers can select on the acquisition
+// alongside other events. The acquisition blocks in the backend, e.g. in
+// F_OFD_SETLKW, or waits for the release of the lock through inotify;
+// cancelling ctx abandons it. A lock acquired after ctx was done is released
+// again, the channel receives the cancellation instead.
+func (l *Locker) AcquireAsync(ctx context.Context) <-chan Acquisition {
+	result := make(chan Acquisition, 1)
+	go func() {
+		g, err := l.Acquire(ctx)
+		if err == nil && ctx.Err() != nil {
+			// nobody may be waiting for the Guard anymore
+			g.Release()
+			g, err = nil, cancelled(ctx.Err())
+		}
+		result <- Acquisition{Guard: g, Err: err}
+	}()
+	return result
+}
+
+// guard returns a Guard owning h.
+func (l *Locker) guard(h *handle) *Guard {
+	l.mu.Lock()
+	logger, clk := l.labeledLogger(), l.clock
+	l.mu.Unlock()
+
+	g := &Guard{&guardState{
+		locker:    l,
+		path:      h.path,
+		heldSince: clk.Now(),
+		logger:    logger,
+		clock:     clk,
+		held:      h,
+		done:      make(chan struct{}),
+	}}
+	l.track(g.guardState)
+	h.held = registerHeld(h.path, g.release)
+	if h.lost != nil {
+		go g.expire(h.lost)
+	}
+	runtime.SetFinalizer(g, finalizeGuard)
+	return g
+}
+
+// finalizeGuard reports and releases Guards which got unreachable without being
+// released.
+func finalizeGuard(g *Guard) {
+	if g.File() == nil {
+		return
+	}
+	g.logger.WithField("path", g.path).Warn("lock guard was garbage collected without being released")
+	g.Release()
+}
+
+// Path returns the absolute path of the locked file.
+func (g *Guard) Path() string {
+	return g.path
+}
+
+// File returns the file holding the lock or nil if the Guard was released.
+func (g *Guard) File() *os.File {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.held == nil {
+		return nil
+	}
+	return g.held.file
+}
+
+// HeldSince returns the time the lock was acquired.
+func (g *Guard) HeldSince() time.Time {
+	return g.heldSince
+}
+
+// ValidFor returns the time the Guard is guaranteed to keep holding the lock,
+// callers should check it before each write to the guarded resource. It's zero
+// once the Guard is released, bounded by a pending handoff and by the lease of
+// the lock since its last renewal, see WithLease, otherwise it's the maximum
+// Duration. The remaining time is measured with the monotonic clock, changes
+// of the wall clock don't affect it.
+func (g *Guard) ValidFor() time.Duration {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.held == nil {
+		return 0
+	}
+	remaining := time.Duration(math.MaxInt64)
+	if g.held.ttl > 0 {
+		remaining = g.locker.leaseRemaining(g.held, g.clock)
+	}
+	if g.bounded {
+		// Since uses the monotonic reading of renewed
+		if handoff := g.validity - g.clock.Since(g.renewed); handoff < remaining {
+			remaining = handoff
+		}
+	}
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// Done returns a channel that's closed when the Guard stops holding the lock.
+// Guards release the lock on their own once its lease is lost, see WithLease.
+func (g *Guard) Done() <-chan struct{} {
+	return g.done
+}
+
+// Release releases the lock. Releasing an already released Guard is a no-op.
+func (g *Guard) Release() error {
+	runtime.SetFinalizer(g, nil)
+	return g.release()
+}
+
+func (s *guardState) release() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.held == nil {
+		return nil
+	}
+	s.locker.untrack(s)
+	if s.expiry != nil {
+		s.expiry.Stop()
+	}
+
+	// it's sufficient to simply close the file descriptor
+	err := s.held.close()
+	s.held = nil
+	close(s.done)
+	if err != nil {
+		return fmt.Errorf("close failed: %w", err)
+	}
+	return nil
+}
+
+// expire releases the Guard once the lease of its lock is lost.
+func (s *guardState) expire(lost <-chan struct{}) {
+	select {
+	case <-lost:
+		s.logger.WithField("path", s.path).Warn("lock lease lost, releasing guard")
+		s.release()
+	case <-s.done:
+	}
+}
+
+// LockUntilDone acquires the lock and releases it as soon as ctx is done. The
+// returned channel receives the result of the release and is closed
+// afterwards. An error is returned if the lock couldn't be acquired before ctx
+// was done.
+func (l *Locker) LockUntilDone(ctx context.Context) (<-chan error, error) {
+	g, err := l.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	released := make(chan error, 1)
+	go func() {
+		defer close(released)
+
+		<-ctx.Done()
+		released <- g.Release()
+	}()
+	return released, nil
+}
+
+type guardKey struct{}
+
+// ContextGuard returns the Guard of the critical section run by Do with ctx or
+// nil if ctx doesn't belong to a critical section.
+func ContextGuard(ctx context.Context) *Guard {
+	g, _ := ctx.Value(guardKey{}).(*Guard)
+	return g
+}
+
+// Do acquires the lock, calls fn and releases the lock after fn returned or
+// panicked. The context passed to fn is cancelled when ctx is done or the lock
+// is no longer held, fn should stop touching the guarded resource then.
+func (l *Locker) Do(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	g, err := l.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.WithValue(ctx, guardKey{}, g))
+	defer func() {
+		cancel()
+		if rerr := g.Release(); rerr != nil && err == nil {
+			err = rerr
+		}
+	}()
+	go func() {
+		select {
+		case <-g.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return fn(ctx)
+}
@@ -0,0 +1,264 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/peertechde/lib/clock"
+)
+
+func TestGuard(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	lock := New(file.Name(), WithRetryInterval(10*time.Millisecond))
+	guard, err := lock.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock.File() != nil {
+		t.Fatal("expected the locker to stay unlocked")
+	}
+	if guard.File() == nil {
+		t.Fatal("expected guard to hold a file")
+	}
+
+	// a second acquisition through the same locker must wait
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := lock.Acquire(ctx); err == nil {
+		t.Fatal("expected acquisition to be cancelled")
+	}
+
+	if err := guard.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if err := guard.Release(); err != nil {
+		t.Fatalf("expected repeated release to succeed, got %v", err)
+	}
+	if guard.File() != nil {
+		t.Fatal("expected released guard to hold no file")
+	}
+
+	again, err := lock.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := again.Release(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockUntilDone(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lock := New(file.Name(), WithRetryInterval(10*time.Millisecond))
+	released, err := lock.LockUntilDone(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dupl := New(file.Name())
+	if err := dupl.TryLock(); err != ErrLockLocked {
+		t.Fatal(err)
+	}
+
+	cancel()
+	select {
+	case err := <-released:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("lock wasn't released")
+	}
+	if _, ok := <-released; ok {
+		t.Fatal("expected release channel to be closed")
+	}
+
+	if err := dupl.TryLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := dupl.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAcquireAsync(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	holder := New(file.Name())
+	if err := holder.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	l := New(file.Name())
+
+	// abandoned acquisitions report the cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	acquired := l.AcquireAsync(ctx)
+	cancel()
+	select {
+	case a := <-acquired:
+		if !errors.Is(a.Err, ErrLockCancelled) || a.Guard != nil {
+			t.Fatalf("expected cancelled acquisition, got %v %v", a.Guard, a.Err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("acquisition wasn't cancelled")
+	}
+
+	acquired = l.AcquireAsync(context.Background())
+	select {
+	case a := <-acquired:
+		t.Fatalf("acquired held lock: %v", a.Err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := holder.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case a := <-acquired:
+		if a.Err != nil {
+			t.Fatal(a.Err)
+		}
+		if err := a.Guard.Release(); err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("lock wasn't acquired after release")
+	}
+}
+
+func TestDo(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	lock := New(file.Name(), WithRetryInterval(10*time.Millisecond))
+	dupl := New(file.Name())
+
+	err = lock.Do(context.Background(), func(ctx context.Context) error {
+		if err := dupl.TryLock(); err != ErrLockLocked {
+			t.Errorf("expected lock to be held, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the lock is released even if fn panics
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected panic to propagate")
+			}
+		}()
+		lock.Do(context.Background(), func(ctx context.Context) error {
+			panic("critical section failed")
+		})
+	}()
+	if err := dupl.TryLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := dupl.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGuardValidFor(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	fake := clock.NewFake(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	lock := New(file.Name())
+	lock.Configure(WithClock(fake))
+	guard, err := lock.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if guard.ValidFor() != math.MaxInt64 {
+		t.Fatalf("expected unbounded validity, got %s", guard.ValidFor())
+	}
+
+	guard.RequestHandoff(time.Minute)
+	fake.Advance(20 * time.Second)
+	if guard.ValidFor() != 40*time.Second {
+		t.Fatalf("expected validity %s, got %s", 40*time.Second, guard.ValidFor())
+	}
+
+	if err := guard.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if guard.ValidFor() != 0 {
+		t.Fatalf("expected no validity after release, got %s", guard.ValidFor())
+	}
+}
+
+func TestGuardValidForLease(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	fake := clock.NewFake(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	lock := New(file.Name(), WithLease(time.Minute))
+	lock.Configure(WithClock(fake))
+	guard, err := lock.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer guard.Release()
+	if guard.ValidFor() != time.Minute {
+		t.Fatalf("expected validity of the lease %s, got %s", time.Minute, guard.ValidFor())
+	}
+
+	// the lease isn't renewed anymore
+	guard.mu.Lock()
+	h := guard.held
+	guard.mu.Unlock()
+	close(h.stop)
+	<-h.stopped
+	h.stop = nil
+
+	fake.Advance(20 * time.Second)
+	if guard.ValidFor() != 40*time.Second {
+		t.Fatalf("expected validity %s, got %s", 40*time.Second, guard.ValidFor())
+	}
+	guard.RequestHandoff(time.Minute)
+	if guard.ValidFor() != 40*time.Second {
+		t.Fatalf("expected validity bounded by the lease %s, got %s", 40*time.Second, guard.ValidFor())
+	}
+	fake.Advance(time.Minute)
+	if guard.ValidFor() != 0 {
+		t.Fatalf("expected no validity after the lease expired, got %s", guard.ValidFor())
+	}
+}
@@ -0,0 +1,77 @@
+package lock
+
+import (
+	"time"
+)
+
+// OnHandoff registers fn to be called when a handoff of the lock is requested,
+// see RequestHandoff. fn is passed the deadline at which the Guard is released
+// forcibly and should finish the critical section before.
+func (g *Guard) OnHandoff(fn func(deadline time.Time)) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.handoffs = append(g.handoffs, fn)
+}
+
+// RequestHandoff asks the holder of the Guard to release the lock within
+// grace. The functions registered with OnHandoff are called and the Guard is
+// released forcibly once grace expired, which also cancels the context of a
+// critical section run by Do. Only the first request has an effect.
+func (g *Guard) RequestHandoff(grace time.Duration) {
+	g.requestHandoff(grace)
+}
+
+// RequestHandoff requests a handoff within grace from every Guard acquired
+// through the Locker which still holds the lock, see Guard.RequestHandoff.
+// Waiters with a higher priority and shutdown handlers use it to make the
+// current holders give up the lock in time.
+func (l *Locker) RequestHandoff(grace time.Duration) {
+	l.mu.Lock()
+	guards := make([]*guardState, 0, len(l.guards))
+	for g := range l.guards {
+		guards = append(guards, g)
+	}
+	l.mu.Unlock()
+
+	for _, g := range guards {
+		g.requestHandoff(grace)
+	}
+}
+
+func (s *guardState) requestHandoff(grace time.Duration) {
+	s.mu.Lock()
+	if s.held == nil || s.expiry != nil {
+		s.mu.Unlock()
+		return
+	}
+	s.bounded, s.renewed, s.validity = true, s.clock.Now(), grace
+	deadline := s.renewed.Add(grace)
+	s.expiry = s.clock.AfterFunc(grace, func() {
+		s.logger.WithField("path", s.path).Warnf("lock wasn't handed off within %s, releasing it", grace)
+		s.release()
+	})
+	handoffs := append([]func(time.Time){}, s.handoffs...)
+	s.mu.Unlock()
+
+	for _, fn := range handoffs {
+		fn(deadline)
+	}
+}
+
+func (l *Locker) track(g *guardState) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.guards == nil {
+		l.guards = make(map[*guardState]struct{})
+	}
+	l.guards[g] = struct{}{}
+}
+
+func (l *Locker) untrack(g *guardState) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.guards, g)
+}
@@ -0,0 +1,68 @@
+package lock
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestHandoff(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	lock := New(file.Name(), WithRetryInterval(10*time.Millisecond))
+	requested := make(chan time.Time, 1)
+	entered := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- lock.Do(context.Background(), func(ctx context.Context) error {
+			ContextGuard(ctx).OnHandoff(func(deadline time.Time) {
+				requested <- deadline
+			})
+			close(entered)
+
+			// ignore the handoff until the lock is released forcibly
+			<-ctx.Done()
+			return ctx.Err()
+		})
+	}()
+	<-entered
+
+	grace := 100 * time.Millisecond
+	before := time.Now()
+	lock.RequestHandoff(grace)
+	select {
+	case deadline := <-requested:
+		if deadline.Before(before.Add(grace)) {
+			t.Fatalf("expected deadline after %s, got %s", before.Add(grace), deadline)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handoff callback wasn't called")
+	}
+
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Fatalf("expected critical section to be cancelled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("lock wasn't released forcibly")
+	}
+	if time.Since(before) < grace {
+		t.Fatal("lock was released before the grace period expired")
+	}
+
+	dupl := New(file.Name())
+	if err := dupl.TryLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := dupl.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
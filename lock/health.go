@@ -0,0 +1,107 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/peertechde/lib/clock"
+)
+
+// HealthChecker is implemented by locks which can check the availability of
+// their backend.
+type HealthChecker interface {
+	// Ping returns an error if the backend of the lock is unavailable.
+	Ping(ctx context.Context) error
+}
+
+var _ HealthChecker = (*Locker)(nil)
+
+// Ping checks that the lock file is accessible and lockable, without
+// acquiring the lock.
+func (l *Locker) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if l.linked() {
+		return l.pingLink()
+	}
+	h, err := l.open()
+	if err != nil {
+		return err
+	}
+	defer h.fs.Close(h.file)
+
+	err = probeLock(h.fs, h.file)
+	if err != nil {
+		return fmt.Errorf("query lock failed: %w", err)
+	}
+	return nil
+}
+
+// Health is the result of a health check.
+type Health struct {
+	Name    string        `json:"name"`
+	Healthy bool          `json:"healthy"`
+	Error   string        `json:"error,omitempty"`
+	Latency time.Duration `json:"latency"`
+}
+
+// CheckHealth pings every checker in parallel, each bounded by timeout. The
+// pings of Lockers are timed by their clock, see WithClock.
+func CheckHealth(ctx context.Context, timeout time.Duration, checkers map[string]HealthChecker) []Health {
+	results := make(chan Health, len(checkers))
+	for name, checker := range checkers {
+		go func(name string, checker HealthChecker) {
+			clk := checkerClock(checker)
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			timer := clk.AfterFunc(timeout, cancel)
+			defer timer.Stop()
+
+			start := clk.Now()
+			err := checker.Ping(ctx)
+			health := Health{Name: name, Healthy: err == nil, Latency: clk.Since(start)}
+			if err != nil {
+				health.Error = err.Error()
+			}
+			results <- health
+		}(name, checker)
+	}
+	healths := make([]Health, 0, len(checkers))
+	for range checkers {
+		healths = append(healths, <-results)
+	}
+	return healths
+}
+
+// checkerClock returns the clock timing the ping of checker.
+func checkerClock(checker HealthChecker) clock.Clock {
+	if l, ok := checker.(*Locker); ok {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		return l.clock
+	}
+	return defaultClock()
+}
+
+// ReadinessHandler returns a http.Handler which responds with 200 OK if all
+// checkers are healthy and with 503 Service Unavailable otherwise. The body
+// lists the result of every checker as JSON.
+func ReadinessHandler(timeout time.Duration, checkers map[string]HealthChecker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		healths := CheckHealth(r.Context(), timeout, checkers)
+		status := http.StatusOK
+		for _, health := range healths {
+			if !health.Healthy {
+				status = http.StatusServiceUnavailable
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(healths)
+	})
+}
@@ -0,0 +1,81 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/peertechde/lib/clock"
+)
+
+func TestReadinessHandler(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	lock := New(file.Name())
+	if err := lock.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// pinging must not interfere with a held lock
+	if err := lock.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := New(file.Name()).Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkers := map[string]HealthChecker{"lock": lock}
+	server := httptest.NewServer(ReadinessHandler(time.Second, checkers))
+	defer server.Close()
+
+	get := func() (int, []Health) {
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var healths []Health
+		if err := json.NewDecoder(resp.Body).Decode(&healths); err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode, healths
+	}
+	if status, healths := get(); status != http.StatusOK || len(healths) != 1 || !healths[0].Healthy {
+		t.Fatalf("expected healthy lock, got %d %+v", status, healths)
+	}
+
+	checkers["missing"] = New(file.Name() + ".missing")
+	if status, _ := get(); status != http.StatusServiceUnavailable {
+		t.Fatalf("expected %d, got %d", http.StatusServiceUnavailable, status)
+	}
+}
+
+func TestCheckHealthClock(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	// the fake clock doesn't advance during the ping
+	fake := clock.NewFake(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	lock := New(file.Name(), WithClock(fake))
+	healths := CheckHealth(context.Background(), time.Second, map[string]HealthChecker{"lock": lock})
+	if len(healths) != 1 || !healths[0].Healthy || healths[0].Latency != 0 {
+		t.Fatalf("expected healthy lock timed by the fake clock, got %+v", healths)
+	}
+}
@@ -0,0 +1,17 @@
+package lock
+
+// HintError annotates an error with a hint on how to resolve it. Permission
+// problems are reported as *PermissionError, which carries a Hint as well.
+type HintError struct {
+	Err  error
+	Hint string
+}
+
+func (e *HintError) Error() string {
+	return e.Err.Error() + ": " + e.Hint
+}
+
+// Unwrap returns the underlying error.
+func (e *HintError) Unwrap() error {
+	return e.Err
+}
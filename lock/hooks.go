@@ -0,0 +1,35 @@
+package lock
+
+import (
+	"time"
+)
+
+// Hooks observes the acquisitions of a Locker, e.g. to export wait times,
+// retry counts and hold durations as metrics, see WithHooks. The path is the
+// path of the Locker, see Path. The hooks are called synchronously by the
+// acquiring goroutine, or by the Scheduler, and must not block; they may be
+// called concurrently for acquisitions of Guards.
+type Hooks interface {
+	// OnAcquireStart is called when an acquisition starts.
+	OnAcquireStart(path string)
+	// OnRetry is called when an acquisition found the lock held elsewhere
+	// and is going to retry, attempt is the number of failed attempts so
+	// far.
+	OnRetry(path string, attempt int)
+	// OnAcquired is called once the lock is acquired, waited is the time
+	// since the start of the acquisition. Acquisitions which fail aren't
+	// reported.
+	OnAcquired(path string, waited time.Duration)
+	// OnReleased is called once the lock is released, held is the time it
+	// was held.
+	OnReleased(path string, held time.Duration)
+}
+
+// NopHooks is a Hooks which observes nothing. Implementations may embed it to
+// observe only some of the events.
+type NopHooks struct{}
+
+func (NopHooks) OnAcquireStart(string)            {}
+func (NopHooks) OnRetry(string, int)              {}
+func (NopHooks) OnAcquired(string, time.Duration) {}
+func (NopHooks) OnReleased(string, time.Duration) {}
@@ -0,0 +1,89 @@
+package lock
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingHooks struct {
+	mu       sync.Mutex
+	started  int
+	retries  int
+	acquired []time.Duration
+	released []time.Duration
+}
+
+func (h *recordingHooks) OnAcquireStart(string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.started++
+}
+
+func (h *recordingHooks) OnRetry(_ string, attempt int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.retries = attempt
+}
+
+func (h *recordingHooks) OnAcquired(_ string, waited time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.acquired = append(h.acquired, waited)
+}
+
+func (h *recordingHooks) OnReleased(_ string, held time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.released = append(h.released, held)
+}
+
+func TestHooks(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	holder := New(file.Name())
+	if err := holder.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	hooks := &recordingHooks{}
+	waiter := New(file.Name(), WithHooks(hooks), WithPolling(true), WithRetryInterval(10*time.Millisecond))
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		holder.Unlock()
+	}()
+	if err := waiter.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := waiter.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := waiter.TryLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := waiter.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	if hooks.started != 2 {
+		t.Fatalf("expected 2 started acquisitions, got %d", hooks.started)
+	}
+	if hooks.retries == 0 {
+		t.Fatal("expected retries to be reported")
+	}
+	if len(hooks.acquired) != 2 || hooks.acquired[0] < 40*time.Millisecond {
+		t.Fatalf("expected 2 acquisitions, the first after waiting, got %v", hooks.acquired)
+	}
+	if len(hooks.released) != 2 || hooks.released[0] < 20*time.Millisecond {
+		t.Fatalf("expected 2 releases, the first after holding, got %v", hooks.released)
+	}
+}
@@ -0,0 +1,33 @@
+package lock
+
+import (
+	"context"
+)
+
+// Interface is the contract shared by lock implementations. A value of
+// Interface represents a single owner of the lock; distinct values exclude
+// each other, even within one process. Packages depending on a lock should
+// accept an Interface, so that tests can inject a Memory lock instead.
+type Interface interface {
+	// Lock blocks until the lock is acquired.
+	Lock() error
+
+	// LockContext blocks like Lock until the lock is acquired or ctx is
+	// done. The error of a cancelled acquisition matches ErrLockCancelled
+	// and the error of ctx with errors.Is.
+	LockContext(ctx context.Context) error
+
+	// TryLock acquires the lock without blocking. It returns ErrLockLocked if
+	// the lock is held by another owner.
+	TryLock() error
+
+	// TryLockContext acquires the lock like TryLock unless ctx is already
+	// done.
+	TryLockContext(ctx context.Context) error
+
+	// Unlock releases the lock. It returns ErrNotLocked if the lock isn't
+	// held.
+	Unlock() error
+}
+
+var _ Interface = (*Locker)(nil)
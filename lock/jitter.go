@@ -0,0 +1,28 @@
+package lock
+
+import (
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// jitterRand randomizes wakeups, it's seeded per process so that waiters of
+// different processes don't wake up in lockstep.
+var jitterRand = struct {
+	sync.Mutex
+	*rand.Rand
+}{
+	Rand: rand.New(rand.NewSource(time.Now().UnixNano() ^ int64(os.Getpid())<<32)),
+}
+
+// jitter returns a random duration within [0, window).
+func jitter(window time.Duration) time.Duration {
+	if window <= 0 {
+		return 0
+	}
+	jitterRand.Lock()
+	defer jitterRand.Unlock()
+
+	return time.Duration(jitterRand.Int63n(int64(window)))
+}
@@ -0,0 +1,72 @@
+package lock
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestJitter(t *testing.T) {
+	if jitter(0) != 0 {
+		t.Fatal("expected no jitter without window")
+	}
+	seen := make(map[time.Duration]bool)
+	for i := 0; i < 100; i++ {
+		d := jitter(time.Second)
+		if d < 0 || d >= time.Second {
+			t.Fatalf("jitter %s out of window", d)
+		}
+		seen[d] = true
+	}
+	if len(seen) < 50 {
+		t.Fatalf("expected random jitter, got %d distinct values", len(seen))
+	}
+}
+
+func TestJitterNotify(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	holder := New(file.Name())
+	if err := holder.Lock(); err != nil {
+		t.Fatal(err)
+	}
+
+	const waiters = 20
+	notified := make(chan time.Time, waiters)
+	for i := 0; i < waiters; i++ {
+		l := New(file.Name(), WithRetryInterval(time.Hour))
+		l.Configure(WithJitter(200 * time.Millisecond))
+		_, free, err := l.TryLockOrNotify(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			<-free
+			notified <- time.Now()
+		}()
+	}
+	if err := holder.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	var first, last time.Time
+	for i := 0; i < waiters; i++ {
+		at := <-notified
+		if first.IsZero() || at.Before(first) {
+			first = at
+		}
+		if at.After(last) {
+			last = at
+		}
+	}
+	if spread := last.Sub(first); spread < 20*time.Millisecond {
+		t.Fatalf("expected notifications to be spread, got %s", spread)
+	}
+}
@@ -0,0 +1,152 @@
+package lock
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/peertechde/lib/clock"
+)
+
+// Done returns a channel that's closed when the lease of the lock held by the
+// Locker is lost, see WithLease. The Locker can't guarantee exclusivity
+// anymore then and should be unlocked. Done returns nil if the lock isn't held
+// or isn't leased.
+func (l *Locker) Done() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.held == nil {
+		return nil
+	}
+	return l.held.lost
+}
+
+// acquired completes the acquisition of h started at start, it records the
+// Owner, starts renewing the lease as configured and reports the acquisition
+// to the Hooks.
+func (l *Locker) acquired(h *handle, start time.Time) error {
+	if err := l.record(h); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	clk, logger, hooks := l.clock, l.labeledLogger(), l.hooks
+	l.mu.Unlock()
+
+	now := clk.Now()
+	if h.ttl > 0 {
+		if err := h.touch(now); err != nil {
+			return err
+		}
+		l.mu.Lock()
+		h.renewed = now
+		l.mu.Unlock()
+		h.lost, h.stop, h.stopped = make(chan struct{}), make(chan struct{}), make(chan struct{})
+		go l.renew(h, clk, logger)
+	}
+	hooks.OnAcquired(l.path, now.Sub(start))
+	h.released = func() { hooks.OnReleased(l.path, clk.Since(now)) }
+	return nil
+}
+
+// takeOver takes the lock of h over if its lease expired, h refers to the file
+// at the path afterwards. It returns ErrLockLocked if the lease is valid, the
+// lock isn't leased or the lock of the replaced file is held as well.
+func (l *Locker) takeOver(h *handle, mode lockMode, rg region) error {
+	if h.ttl == 0 {
+		return ErrLockLocked
+	}
+	current, err := h.current()
+	if err != nil {
+		return err
+	}
+	if current {
+		fi, err := h.file.Stat()
+		if err != nil {
+			return fmt.Errorf("stat failed: %w", err)
+		}
+		l.mu.Lock()
+		clk, logger := l.clock, l.labeledLogger()
+		l.mu.Unlock()
+
+		if clk.Since(fi.ModTime()) < h.ttl {
+			return ErrLockLocked
+		}
+		logger.WithField("path", h.path).Warnf("lock lease expired %s ago, taking over", clk.Since(fi.ModTime())-h.ttl)
+		if err := replaceFile(h.path, fi.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	// the file was replaced, by this or another waiter
+	h.fs.Close(h.file)
+	reopened, err := l.open()
+	if err != nil {
+		return err
+	}
+	*h = *reopened
+	return setLock(h.fs, h.file, mode, rg)
+}
+
+// leaseRemaining returns the time the lease of h is valid for since its last
+// renewal, it's zero once the lease expired.
+func (l *Locker) leaseRemaining(h *handle, clk clock.Clock) time.Duration {
+	l.mu.Lock()
+	renewed := h.renewed
+	l.mu.Unlock()
+
+	remaining := h.ttl - clk.Since(renewed)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// touch renews the lease of h at now. It touches the file of h rather than
+// the file at its path, which is the file of the new holder once the lock was
+// taken over.
+func (h *handle) touch(now time.Time) error {
+	if err := touchFile(h.file, now); err != nil {
+		return fmt.Errorf("renew lease failed: %w", err)
+	}
+	return nil
+}
+
+// renew renews the lease of h every third of its TTL until h is closed. The
+// lease is lost once the file is replaced by a waiter taking over, or if it
+// couldn't be renewed within the TTL.
+func (l *Locker) renew(h *handle, clk clock.Clock, logger logrus.FieldLogger) {
+	defer close(h.stopped)
+
+	for {
+		timer := clk.NewTimer(h.ttl / 3)
+		select {
+		case <-h.stop:
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+		current, err := h.current()
+		if err == nil && !current {
+			logger.WithField("path", h.path).Warn("lock lease taken over")
+			close(h.lost)
+			return
+		}
+		now := clk.Now()
+		if err == nil {
+			err = h.touch(now)
+		}
+		if err == nil {
+			l.mu.Lock()
+			h.renewed = now
+			l.mu.Unlock()
+			continue
+		}
+		if l.leaseRemaining(h, clk) == 0 {
+			logger.WithField("path", h.path).WithError(err).Warn("lock lease expired")
+			close(h.lost)
+			return
+		}
+		logger.WithField("path", h.path).WithError(err).Debug("lock lease renewal failed")
+	}
+}
@@ -0,0 +1,95 @@
+package lock
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestLease(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("open files can't be replaced on windows")
+	}
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	ttl := 150 * time.Millisecond
+	l1 := New(file.Name(), WithLease(ttl))
+	l2 := New(file.Name(), WithLease(ttl), WithRetryInterval(10*time.Millisecond))
+	if l1.Done() != nil {
+		t.Fatal("expected unlocked Locker to have no lease")
+	}
+
+	// the holder keeps renewing the lease
+	if err := l1.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * ttl)
+	if err := l2.TryLock(); err != ErrLockLocked {
+		t.Fatalf("expected renewed lease to be valid, got %v", err)
+	}
+	select {
+	case <-l1.Done():
+		t.Fatal("lost renewed lease")
+	default:
+	}
+
+	// the lease of a hung holder expires and is taken over
+	h := l1.held
+	close(h.stop)
+	<-h.stopped
+	h.stop = nil
+	old := time.Now().Add(-2 * ttl)
+	if err := os.Chtimes(file.Name(), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := l2.TryLock(); err != nil {
+		t.Fatalf("expected expired lease to be taken over, got %v", err)
+	}
+	if err := New(file.Name()).TryLock(); err != ErrLockLocked {
+		t.Fatalf("expected lock to be held by the new holder, got %v", err)
+	}
+
+	// a renewal of the old holder doesn't renew the lease of the new one
+	future := time.Now().Add(time.Hour)
+	if err := h.touch(future); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.ModTime().After(time.Now().Add(time.Minute)) {
+		t.Fatal("expected the old holder to renew its replaced file")
+	}
+	if err := l1.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Guards are released once their lease is lost
+	if err := l2.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	g, err := New(file.Name(), WithLease(ttl)).Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := New(file.Name()).ForceUnlock(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-g.Done():
+	case <-time.After(ttl):
+		t.Fatal("lease loss wasn't noticed")
+	}
+	if g.File() != nil {
+		t.Fatal("expected guard to be released")
+	}
+}
@@ -0,0 +1,308 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/peertechde/lib/clock"
+)
+
+// Strategy selects how a Locker locks, see WithStrategy.
+type Strategy int
+
+const (
+	// StrategyFile locks the file with the locks of the backend, see
+	// Backend. It's the default.
+	StrategyFile Strategy = iota
+	// StrategyLinkLock locks by creating the lock file, the classic lock
+	// file algorithm for network file systems whose record locks are
+	// unreliable. The file at the path exists while the lock is held: a
+	// unique file recording the Owner is linked to the path, which fails if
+	// the path exists, and the link is verified by comparing the files, as
+	// NFS may report a link failure although it succeeded. Unlock removes the
+	// file. Locks whose holder is known to be gone, see IsStale, or whose
+	// lease expired, see WithLease, are removed by the next waiter.
+	//
+	// Link locks are always exclusive and polled, shared locks, byte ranges,
+	// conversions, WaitQueues, Schedulers and TryLockOrNotify aren't
+	// supported.
+	StrategyLinkLock
+)
+
+// errLinkLock is returned by the operations link locks don't support.
+var errLinkLock = errors.New("operation isn't supported by link locks")
+
+// linkSeq makes the names of the files linked by the process unique.
+var linkSeq uint64
+
+// linked reports whether the Locker uses link locks.
+func (l *Locker) linked() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.strategy == StrategyLinkLock
+}
+
+// linkPath returns the path of the lock file of a link lock, the lock file
+// within the directory for directory locks.
+func (l *Locker) linkPath() (string, error) {
+	abs, err := filepath.Abs(l.path)
+	if err != nil {
+		return "", fmt.Errorf("absolute represenation of path failed: %w", err)
+	}
+	l.mu.Lock()
+	fs, name := l.fs, l.dirLockName
+	l.mu.Unlock()
+
+	if fi, err := fs.Stat(abs); err == nil && fi.IsDir() {
+		abs = filepath.Join(abs, name)
+	}
+	return abs, nil
+}
+
+// openLink opens the lock file of the link lock for reading, it returns a nil
+// file if the lock is free.
+func (l *Locker) openLink() (FileSystem, *os.File, error) {
+	path, err := l.linkPath()
+	if err != nil {
+		return nil, nil, err
+	}
+	l.mu.Lock()
+	fs := l.fs
+	l.mu.Unlock()
+
+	file, err := fs.OpenFile(path, os.O_RDONLY, 0)
+	if os.IsNotExist(err) {
+		return fs, nil, nil
+	}
+	if err != nil {
+		return nil, nil, openFailed(path, err)
+	}
+	return fs, file, nil
+}
+
+// acquireLink acquires the link lock, see StrategyLinkLock.
+func (l *Locker) acquireLink(ctx context.Context, expired <-chan time.Time, clk clock.Clock, start time.Time, mode lockMode, rg region, block bool) (*handle, error) {
+	if mode != modeExclusive || rg != (region{}) {
+		return nil, fmt.Errorf("shared or partial link lock: %w", errLinkLock)
+	}
+	path, err := l.linkPath()
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	fs, ttl := l.fs, l.lease
+	l.mu.Unlock()
+
+	var h *handle
+	err = l.retry(ctx, expired, clk, path, block, func() error {
+		file, err := l.link(path, clk)
+		if err == ErrLockLocked {
+			if stale, err := l.breakStale(path, clk, ttl); err != nil || !stale {
+				return ErrLockLocked
+			}
+			file, err = l.link(path, clk)
+		}
+		if err != nil {
+			return err
+		}
+		h = &handle{fs: fs, file: file, path: path, mode: modeExclusive, link: true, ttl: ttl}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := l.acquired(h, start); err != nil {
+		h.close()
+		return nil, err
+	}
+	return h, nil
+}
+
+// link links a unique file recording the Owner to path and returns the file
+// at path opened for reading. It returns ErrLockLocked if path exists.
+func (l *Locker) link(path string, clk clock.Clock) (*os.File, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("hostname failed: %w", err)
+	}
+	l.mu.Lock()
+	fs, perm, label, key := l.fs, l.perm, l.ownerLabel, l.ownerKey
+	l.mu.Unlock()
+
+	data, err := encodeOwner(&Owner{
+		Version:  ownerVersion,
+		PID:      os.Getpid(),
+		Hostname: hostname,
+		Since:    clk.Now(),
+		Label:    label,
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+	unique := fmt.Sprintf("%s.%s.%d.%d", path, hostname, os.Getpid(), atomic.AddUint64(&linkSeq, 1))
+	tmp, err := fs.OpenFile(unique, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return nil, openFailed(unique, err)
+	}
+	_, err = tmp.Write(data)
+	if cerr := fs.Close(tmp); err == nil {
+		err = cerr
+	}
+	defer os.Remove(unique)
+	if err != nil {
+		return nil, fmt.Errorf("write owner failed: %w", err)
+	}
+
+	// the result of link isn't reliable on NFS, the files are compared
+	// instead
+	lerr := os.Link(unique, path)
+	file, err := fs.OpenFile(path, os.O_RDONLY, 0)
+	if os.IsNotExist(err) {
+		if lerr != nil {
+			return nil, fmt.Errorf("link failed: %w", lerr)
+		}
+		// removed right away by a waiter breaking the lock
+		return nil, ErrLockLocked
+	}
+	if err != nil {
+		return nil, openFailed(path, err)
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		fs.Close(file)
+		return nil, fmt.Errorf("stat failed: %w", err)
+	}
+	uniqueFi, err := fs.Stat(unique)
+	if err != nil || !os.SameFile(fi, uniqueFi) {
+		fs.Close(file)
+		if lerr != nil && !os.IsExist(lerr) {
+			return nil, fmt.Errorf("link failed: %w", lerr)
+		}
+		return nil, ErrLockLocked
+	}
+	return file, nil
+}
+
+// breakStale removes the lock file at path if its holder is known to be gone
+// or its lease expired, and reports whether it did.
+func (l *Locker) breakStale(path string, clk clock.Clock, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	fs, logger, key := l.fs, l.labeledLogger(), l.ownerKey
+	l.mu.Unlock()
+
+	file, err := fs.OpenFile(path, os.O_RDONLY, 0)
+	if os.IsNotExist(err) {
+		// released in the meantime
+		return true, nil
+	}
+	if err != nil {
+		return false, openFailed(path, err)
+	}
+	fi, stale, err := linkExpired(file, clk, ttl, key)
+	// Windows doesn't allow moving open files
+	fs.Close(file)
+	if err != nil || !stale {
+		return false, err
+	}
+
+	// the stale file is moved aside before it's removed, so that a lock
+	// file linked by another waiter in between isn't removed instead
+	aside := fmt.Sprintf("%s.stale.%d.%d", path, os.Getpid(), atomic.AddUint64(&linkSeq, 1))
+	if err := os.Rename(path, aside); err != nil {
+		if os.IsNotExist(err) {
+			return true, nil
+		}
+		return false, fmt.Errorf("move stale lock aside failed: %w", err)
+	}
+	asideFi, err := fs.Stat(aside)
+	if err == nil && !os.SameFile(fi, asideFi) {
+		// a fresh lock file was moved, put it back unless another one
+		// took its place
+		os.Link(aside, path)
+		os.Remove(aside)
+		return false, nil
+	}
+	os.Remove(aside)
+	logger.WithField("path", path).Warn("removed stale link lock")
+	return true, nil
+}
+
+// linkExpired reports whether the holder of the lock file is known to be gone
+// or its lease expired, and returns the FileInfo of the file. The Owner is
+// verified with key, see readOwner.
+func linkExpired(file *os.File, clk clock.Clock, ttl time.Duration, key []byte) (os.FileInfo, bool, error) {
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, false, fmt.Errorf("stat failed: %w", err)
+	}
+	if ttl > 0 && clk.Since(fi.ModTime()) >= ttl {
+		return fi, true, nil
+	}
+	owner, err := readOwner(file, key)
+	if err != nil {
+		return nil, false, err
+	}
+	gone, err := owner.gone()
+	return fi, gone, err
+}
+
+// linkStale reports whether the holder of the link lock is known to be gone
+// or its lease expired, see IsStale.
+func (l *Locker) linkStale() (bool, error) {
+	fs, file, err := l.openLink()
+	if err != nil || file == nil {
+		return false, err
+	}
+	defer fs.Close(file)
+
+	if expired, err := l.leaseExpired(file); err != nil || expired {
+		return expired, err
+	}
+	owner, err := readOwner(file, l.key())
+	if err != nil {
+		return false, err
+	}
+	return owner.gone()
+}
+
+// removeLink removes the lock file of the link lock, see ForceUnlock.
+func (l *Locker) removeLink() error {
+	path, err := l.linkPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove lock file failed: %w", err)
+	}
+	return nil
+}
+
+// pingLink checks that the directory of the lock file of the link lock is
+// accessible, see Ping.
+func (l *Locker) pingLink() error {
+	path, err := l.linkPath()
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	fs := l.fs
+	l.mu.Unlock()
+
+	if _, err := fs.Stat(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("stat failed: %w", err)
+	}
+	return nil
+}
+
+// holdsLink reports whether the lock file at the path of h is still the file
+// of h, which it isn't once the lock was broken.
+func (h *handle) holdsLink() bool {
+	current, err := h.current()
+	return err == nil && current
+}
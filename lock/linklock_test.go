@@ -0,0 +1,113 @@
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLinkLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "link.lock")
+
+	l1 := New(path, WithStrategy(StrategyLinkLock), WithOwner("first"))
+	l2 := New(path, WithStrategy(StrategyLinkLock), WithRetryInterval(10*time.Millisecond), WithTimeout(50*time.Millisecond))
+	if err := l1.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected lock file to exist while held, got %v", err)
+	}
+	owner, err := l2.Holder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner == nil || owner.PID != os.Getpid() || owner.Label != "first" {
+		t.Fatalf("expected holder %d, got %v", os.Getpid(), owner)
+	}
+	if err := l2.TryLock(); !errors.Is(err, ErrLockLocked) {
+		t.Fatalf("expected %v, got %v", ErrLockLocked, err)
+	}
+	if err := l2.Lock(); err != ErrLockTimeout {
+		t.Fatalf("expected %v, got %v", ErrLockTimeout, err)
+	}
+	if err := l2.RLock(); !errors.Is(err, errLinkLock) {
+		t.Fatalf("expected %v, got %v", errLinkLock, err)
+	}
+
+	if err := l1.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected lock file to be removed on unlock, got %v", err)
+	}
+	if owner, err := l2.Holder(); err != nil || owner != nil {
+		t.Fatalf("expected no holder, got %v %v", owner, err)
+	}
+	if err := l2.TryLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l2.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the files linked to the path are cleaned up
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected empty directory, got %d entries", len(entries))
+	}
+}
+
+func TestLinkLockStale(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "link.lock")
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(&Owner{Version: ownerVersion, PID: deadPID(t), Hostname: hostname})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l := New(path, WithStrategy(StrategyLinkLock))
+	stale, err := l.IsStale()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stale {
+		t.Fatal("expected lock of exited process to be stale")
+	}
+	if err := l.TryLock(); err != nil {
+		t.Fatal(err)
+	}
+	owner, err := l.Holder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner == nil || owner.PID != os.Getpid() {
+		t.Fatalf("expected holder %d, got %v", os.Getpid(), owner)
+	}
+	if err := l.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,140 +1,702 @@
 package lock
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
-	"github.com/pkg/errors"
-	"golang.org/x/sys/unix"
+	"github.com/sirupsen/logrus"
+
+	"github.com/peertechde/lib/clock"
+	"github.com/peertechde/lib/log"
 )
 
 const (
 	defaultRetryInterval = 250 * time.Millisecond
+	defaultPerm          = 0660
+	defaultDirLockName   = ".lock"
+)
+
+// Backend identifies the locking mechanism used by a Locker.
+type Backend string
 
-	// Open File Description Locks
-	//
-	// Usually record locks held by a process are released on *any* close and are
-	// not inherited across a fork().
-	// These cmd values will set locks that conflict with process-associated
-	// record  locks, but are "owned" by the open file description, not the
-	// process. This means that they are inherited across fork() like BSD (flock)
-	// locks, and they are only released automatically when the last reference to
-	// the the open file against which they were acquired is put.
-	//
-	// source /usr/include/bits/fcntl-linux.h
-	F_OFD_GETLK  = 37
-	F_OFD_SETLK  = 37
-	F_OFD_SETLKW = 38
+const (
+	// BackendOFD uses open file description locks.
+	BackendOFD Backend = "ofd"
 )
 
-var (
-	ErrLockLocked = fmt.Errorf("lock: lock is locked")
+// region is a byte range of a file. A zero len extends the region to the end
+// of the file, however far it grows; the zero region covers the whole file.
+type region struct {
+	offset, len int64
+}
+
+// lockMode is the mode a lock is held in.
+type lockMode int
+
+const (
+	modeExclusive lockMode = iota
+	modeShared
 )
 
-// New returns a new Locker
-func New(path string, retryInterval time.Duration) *Locker {
-	if retryInterval == time.Duration(0) {
-		retryInterval = defaultRetryInterval
-	}
-	return &Locker{
+// New returns a new Locker of the file at path, configured by opts.
+func New(path string, opts ...Option) *Locker {
+	l := &Locker{
 		path:          path,
-		retryInterval: retryInterval,
+		retryInterval: defaultRetryInterval,
+		logger:        log.Logger,
+		fs:            OSFileSystem,
+		clock:         defaultClock(),
+		perm:          defaultPerm,
+		dirLockName:   defaultDirLockName,
+		hooks:         NopHooks{},
 	}
+	l.Configure(opts...)
+	return l
 }
 
+// Locker is a lock of a file. Every acquisition opens the file, the locks of
+// the backends belong to the open file rather than the process, so Lockers of
+// the same file exclude each other within the process as well as across
+// processes. A Locker is safe for concurrent use by multiple goroutines; like
+// a sync.Mutex, it's held by a single owner at a time and Lock blocks while
+// another goroutine holds the Locker.
 type Locker struct {
-	path          string
-	file          *os.File
-	retryInterval time.Duration
+	path string
+
+	// mu guards the state of the acquisition, the configuration and the
+	// Guards below, see Configure
+	mu             sync.Mutex
+	resolved       string
+	held           *handle
+	heldSince      time.Time
+	retryInterval  time.Duration
+	timeout        time.Duration
+	logger         logrus.FieldLogger
+	fs             FileSystem
+	clock          clock.Clock
+	queue          *WaitQueue
+	priority       int
+	expectedHold   time.Duration
+	jitter         time.Duration
+	polling        bool
+	backoff        Backoff
+	create         bool
+	perm           os.FileMode
+	flags          int
+	readOnly       bool
+	dirLockName    string
+	dirCleanup     bool
+	removeOnUnlock bool
+	owner          bool
+	ownerLabel     string
+	ownerKey       []byte
+	lease          time.Duration
+	strategy       Strategy
+	hooks          Hooks
+	labels         map[string]string
+	guards         map[*guardState]struct{}
+}
+
+// Path returns the path the Locker was created with.
+func (l *Locker) Path() string {
+	return l.path
+}
+
+// ResolvedPath returns the absolute path resolved during the last successful
+// acquisition, the path of the lock file within the directory for directory
+// locks. It returns an empty string if the lock was never acquired.
+func (l *Locker) ResolvedPath() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.resolved
+}
+
+// RetryInterval returns the interval between acquisition attempts of Lock in
+// polling mode, see WithPolling.
+func (l *Locker) RetryInterval() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.retryInterval
+}
+
+// Labels returns a copy of the labels of the Locker, see WithLabels.
+func (l *Locker) Labels() map[string]string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	labels := make(map[string]string, len(l.labels))
+	for k, v := range l.labels {
+		labels[k] = v
+	}
+	return labels
 }
 
-// todo:
-// Lock locks ...
+// Backend returns the locking mechanism used by the Locker.
+func (l *Locker) Backend() Backend {
+	return fileBackend
+}
+
+// File returns the file holding the lock or nil if the lock isn't held.
+func (l *Locker) File() *os.File {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.held == nil {
+		return nil
+	}
+	return l.held.file
+}
+
+// Fd returns the descriptor of the file holding the lock or ^uintptr(0) if the
+// lock isn't held. Child processes inherit the descriptor through
+// cmd.ExtraFiles and take the lock over with FromFd on Linux.
+func (l *Locker) Fd() uintptr {
+	file := l.File()
+	if file == nil {
+		return ^uintptr(0)
+	}
+	return file.Fd()
+}
+
+// HeldSince returns the time the lock was acquired or the zero time if the
+// lock isn't held.
+func (l *Locker) HeldSince() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.heldSince
+}
+
+// IsLocked reports whether the Locker holds the lock.
+func (l *Locker) IsLocked() bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.held != nil
+}
+
+// Lock acquires the lock exclusively. It blocks while another Locker holds
+// the lock, until the lock is released or the timeout expired, see
+// WithTimeout and WithPolling; it returns ErrLockTimeout once it expired.
 func (l *Locker) Lock() error {
-	abs, err := filepath.Abs(l.path)
+	return l.LockContext(context.Background())
+}
+
+// LockContext locks like Lock but gives up once ctx is done. The error of a
+// cancelled acquisition matches ErrLockCancelled and the error of ctx with
+// errors.Is.
+func (l *Locker) LockContext(ctx context.Context) error {
+	debugLock(l)
+	h, err := l.acquire(ctx, modeExclusive, region{}, true)
 	if err != nil {
-		return errors.Wrap(err, "absolute represenation of path failed")
+		return err
 	}
-	fi, err := os.Stat(abs)
+	return l.hold(h)
+}
+
+// TryLock acquires the lock exclusively like Lock without blocking. It
+// returns ErrLockLocked if another Locker holds the lock, annotated with the
+// holder as a *HintError if the holder recorded itself, see WithOwner.
+func (l *Locker) TryLock() error {
+	return l.TryLockContext(context.Background())
+}
+
+// TryLockContext locks like TryLock unless ctx is already done.
+func (l *Locker) TryLockContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return cancelled(err)
+	}
+	debugLock(l)
+	h, err := l.acquire(ctx, modeExclusive, region{}, false)
+	if err != nil {
+		return err
+	}
+	return l.hold(h)
+}
+
+// TryLockFor locks like Lock but gives up after d, regardless of the timeout
+// of the Locker, returning ErrLockTimeout. A d of zero or less makes a single
+// attempt like TryLock.
+func (l *Locker) TryLockFor(d time.Duration) error {
+	debugLock(l)
+	h, err := l.acquireWithin(context.Background(), modeExclusive, region{}, d > 0, d)
+	if err != nil {
+		return err
+	}
+	return l.hold(h)
+}
+
+// Unlock releases the lock held by the Locker, which may be locked again
+// afterwards. Unlock returns ErrNotLocked if the Locker doesn't hold the lock,
+// e.g. if it's unlocked twice.
+func (l *Locker) Unlock() error {
+	if l == nil {
+		return ErrNotLocked
+	}
+	debugUnlock(l)
+	// the state is reset before the release, a goroutine waiting for the
+	// Locker may acquire it right away
+	l.mu.Lock()
+	h := l.held
+	l.held, l.heldSince = nil, time.Time{}
+	l.mu.Unlock()
+
+	if h == nil {
+		return ErrNotLocked
+	}
+
+	// it's sufficient to simply close the file descriptor
+	if err := h.close(); err != nil {
+		return fmt.Errorf("close failed: %w", err)
+	}
+	return nil
+}
+
+// hold makes h the lock held by the Locker. The Locker holds a single lock at a
+// time; shared locks and disjoint ranges don't exclude each other though, if
+// the Locker holds one already, h is released and hold returns
+// ErrAlreadyLocked.
+func (l *Locker) hold(h *handle) error {
+	h.held = registerHeld(h.path, l.Unlock)
+	now := l.now()
+	l.mu.Lock()
+	if l.held != nil {
+		l.mu.Unlock()
+		h.close()
+		return ErrAlreadyLocked
+	}
+	l.resolved, l.held, l.heldSince = h.path, h, now
+	l.mu.Unlock()
+	debugLocked(l)
+	return nil
+}
+
+// defaultClock returns the clock of new Lockers, the precise clock if it's
+// available. Its timers wake up retrying Lockers right after the retry
+// interval.
+func defaultClock() clock.Clock {
+	c, err := clock.Precise()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return errors.Wrap(err, "path doesn't exist")
+		restrict("timerfd", err)
+		return clock.Real
+	}
+	return c
+}
+
+// labeledLogger returns the logger of the Locker with the labels as fields.
+// l.mu must be held.
+func (l *Locker) labeledLogger() logrus.FieldLogger {
+	if len(l.labels) == 0 {
+		return l.logger
+	}
+	fields := make(logrus.Fields, len(l.labels))
+	for k, v := range l.labels {
+		fields[k] = v
+	}
+	return l.logger.WithFields(fields)
+}
+
+// now returns the current time of the clock of the Locker.
+func (l *Locker) now() time.Time {
+	l.mu.Lock()
+	clk := l.clock
+	l.mu.Unlock()
+
+	return clk.Now()
+}
+
+// handle is a locked file together with the FileSystem it was opened with.
+type handle struct {
+	fs     FileSystem
+	file   *os.File
+	path   string
+	mode   lockMode
+	region region
+	// remove removes the file on release, see WithDirCleanup and
+	// WithRemoveOnUnlock
+	remove bool
+	// owned is set once the Owner was recorded in the file, see WithOwner
+	owned bool
+	// link is set for link locks, the file is removed on release while it's
+	// the file at the path, see StrategyLinkLock
+	link bool
+	// ttl is the TTL of the lease of the lock, see WithLease; lost is closed
+	// once the lease is lost, stop stops its renewal. renewed is the time of
+	// the last renewal, guarded by the mu of the Locker.
+	ttl     time.Duration
+	renewed time.Time
+	lost    chan struct{}
+	stop    chan struct{}
+	stopped chan struct{}
+
+	// released reports the release to the Hooks of the Locker
+	released func()
+
+	// held identifies the handle for OnShutdown
+	held uint64
+	// turn passes the turn in the WaitQueue on, if any
+	turn func()
+}
+
+func (h *handle) close() error {
+	if h == nil {
+		return ErrNotLocked
+	}
+	unregisterHeld(h.held)
+	if h.stop != nil {
+		close(h.stop)
+		<-h.stopped
+	}
+	// the lock file of a link lock broken by a waiter isn't removed, it's
+	// the lock file of the next holder
+	unlink := h.link && h.holdsLink()
+	if h.owned {
+		// the record of a released lock would name a former holder
+		h.file.Truncate(0)
+	}
+	if h.remove && h.last() {
+		os.Remove(h.path)
+	}
+	err := h.fs.Close(h.file)
+	if unlink {
+		os.Remove(h.path)
+	}
+	if h.released != nil {
+		h.released()
+	}
+	if h.turn != nil {
+		h.turn()
+	}
+	return err
+}
+
+// last reports whether h is the last holder of the file at its path, which
+// removes the file on release. The conversion fails while others hold the lock
+// shared or other ranges of the file; the file at the path belongs to others
+// once it was replaced.
+func (h *handle) last() bool {
+	if h.mode != modeExclusive || h.region != (region{}) {
+		if convertLock(h.fs, h.file, modeExclusive, region{}) != nil {
+			return false
 		}
-		return errors.Wrap(err, "stat failed")
 	}
-	if fi.IsDir() {
-		return errors.New("directory not allowed")
+	current, err := h.current()
+	return err == nil && current
+}
+
+// current reports whether the file of the handle is still the file at its
+// path, which it isn't once the file was removed by another holder.
+func (h *handle) current() (bool, error) {
+	fi, err := h.file.Stat()
+	if err != nil {
+		return false, fmt.Errorf("stat failed: %w", err)
+	}
+	pathFi, err := h.fs.Stat(h.path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("stat failed: %w", err)
+	}
+	return os.SameFile(fi, pathFi), nil
+}
+
+// acquire opens and locks region of the file at the path of the Locker in
+// mode. If block is false, acquire returns ErrLockLocked instead of retrying
+// while the lock is held elsewhere, otherwise it retries until the timeout of
+// the Locker expired.
+func (l *Locker) acquire(ctx context.Context, mode lockMode, rg region, block bool) (*handle, error) {
+	l.mu.Lock()
+	timeout := l.timeout
+	l.mu.Unlock()
+
+	return l.acquireWithin(ctx, mode, rg, block, timeout)
+}
+
+// acquireWithin acquires like acquire, retrying until timeout expired instead
+// of the timeout of the Locker.
+func (l *Locker) acquireWithin(ctx context.Context, mode lockMode, rg region, block bool, timeout time.Duration) (*handle, error) {
+	l.mu.Lock()
+	clk, readOnly, hooks := l.clock, l.readOnly, l.hooks
+	queue, priority, hold := l.queue, l.priority, l.expectedHold
+	l.mu.Unlock()
+
+	if readOnly && mode == modeExclusive {
+		return nil, errReadOnly
+	}
+	start := clk.Now()
+	hooks.OnAcquireStart(l.path)
+	if !block {
+		timeout = 0
+	}
+	expired, stop := expiry(clk, timeout)
+	defer stop()
+
+	if l.linked() {
+		h, err := l.acquireLink(ctx, expired, clk, start, mode, rg, block)
+		return h, l.holderHint(err)
 	}
-	file, err := os.OpenFile(abs, os.O_RDWR, 0660)
+	h, err := l.open()
 	if err != nil {
-		return errors.Wrap(err, "open failed")
+		return nil, err
+	}
+	var turn func()
+	if queue != nil {
+		turn, err = queue.wait(ctx, expired, h.path, priority, hold, block)
+		if err != nil {
+			h.fs.Close(h.file)
+			return nil, err
+		}
+	}
+	// fail closes the file and passes the turn on
+	fail := func(err error) (*handle, error) {
+		if h != nil {
+			h.fs.Close(h.file)
+		}
+		if turn != nil {
+			turn()
+		}
+		return nil, l.holderHint(err)
 	}
+	l.mu.Lock()
+	polling := l.polling
+	l.mu.Unlock()
+
 	for {
-		err = unix.FcntlFlock(file.Fd(), F_OFD_SETLK, &unix.Flock_t{
-			Type:   unix.F_WRLCK,
-			Whence: int16(io.SeekStart),
-		})
-		if err == nil {
+		if block && !polling && h.ttl == 0 {
+			err = l.wait(ctx, expired, clk, h.fs, h.file, h.path, mode, rg)
+		} else {
+			err = l.retry(ctx, expired, clk, h.path, block, func() error {
+				err := setLock(h.fs, h.file, mode, rg)
+				if err == ErrLockLocked {
+					return l.takeOver(h, mode, rg)
+				}
+				return err
+			})
+		}
+		if err != nil {
+			return fail(err)
+		}
+		if !h.remove && h.ttl == 0 {
+			break
+		}
+		// the lock of a file removed by its previous holder or replaced by a
+		// waiter taking over its lease excludes nobody, the file at the path
+		// is locked instead
+		current, err := h.current()
+		if err != nil {
+			return fail(err)
+		}
+		if current {
 			break
 		}
-		if err != unix.EWOULDBLOCK {
-			file.Close()
-			return errors.Wrap(err, "lock failed")
+		h.fs.Close(h.file)
+		if h, err = l.open(); err != nil {
+			return fail(err)
 		}
-		time.Sleep(l.retryInterval)
 	}
-	l.path = abs
-	l.file = file
-
-	return nil
+	if mode == modeShared && turn != nil {
+		// shared locks don't exclude each other, the next waiter may proceed
+		turn()
+		turn = nil
+	}
+	h.mode, h.region, h.turn = mode, rg, turn
+	if err := l.acquired(h, start); err != nil {
+		return fail(err)
+	}
+	return h, nil
 }
 
-// todo:
-// TryLock ...
-func (l *Locker) TryLock() error {
-	abs, err := filepath.Abs(l.path)
-	if err != nil {
-		return errors.Wrap(err, "abs failed")
+// expiry returns the channel receiving once timeout expired, if it's
+// positive, and the function stopping its timer.
+func expiry(clk clock.Clock, timeout time.Duration) (<-chan time.Time, func()) {
+	if timeout <= 0 {
+		return nil, func() {}
 	}
-	fi, err := os.Stat(abs)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return errors.Wrap(err, "path doesn't exist")
+	timer := clk.NewTimer(timeout)
+	return timer.C(), func() { timer.Stop() }
+}
+
+// retry calls try to set the lock at abs. If block is true, it retries as
+// scheduled by the Backoff of the Locker while the lock is held elsewhere,
+// until ctx is done or expired receives.
+func (l *Locker) retry(ctx context.Context, expired <-chan time.Time, clk clock.Clock, abs string, block bool, try func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := try()
+		if err == nil {
+			return nil
+		}
+		if err != ErrLockLocked {
+			return lockFailed(abs, err)
+		}
+		if !block {
+			return ErrLockLocked
+		}
+		l.mu.Lock()
+		interval, logger, hooks := l.delay(attempt), l.labeledLogger(), l.hooks
+		l.mu.Unlock()
+
+		hooks.OnRetry(l.path, attempt+1)
+		logger.WithField("path", abs).Debugf("lock is locked, retrying in %s", interval)
+		retry := clk.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			retry.Stop()
+			return cancelled(ctx.Err())
+		case <-expired:
+			retry.Stop()
+			return ErrLockTimeout
+		case <-retry.C():
 		}
-		return errors.Wrap(err, "stat failed")
 	}
-	if fi.IsDir() {
-		return errors.New("directories are not allowed")
+}
+
+// wait acquires the lock of region of file in mode, blocking until the lock is released
+// elsewhere. If nothing but the release ends the wait, it blocks in the
+// backend, e.g. in F_OFD_SETLKW. The blocked call can't be interrupted though,
+// the Go runtime installs its signal handlers with SA_RESTART. Otherwise wait
+// retries whenever the file is closed by any process, noticed through a
+// closeWatch which is interrupted through a pipe once ctx is done or expired
+// receives. Without a closeWatch, wait polls like retry.
+func (l *Locker) wait(ctx context.Context, expired <-chan time.Time, clk clock.Clock, fs FileSystem, file *os.File, abs string, mode lockMode, rg region) error {
+	try := func() error { return setLock(fs, file, mode, rg) }
+	if ctx.Done() == nil && expired == nil {
+		err := try()
+		if err == ErrLockLocked {
+			l.mu.Lock()
+			logger := l.labeledLogger()
+			l.mu.Unlock()
+
+			logger.WithField("path", abs).Debug("lock is locked, waiting")
+			err = waitLock(fs, file, mode, rg)
+		}
+		if err != nil {
+			return lockFailed(abs, err)
+		}
+		return nil
 	}
-	file, err := os.OpenFile(abs, os.O_RDWR, 0660)
+
+	// the watch is set up before the first attempt, a release in between
+	// isn't missed
+	watch, err := newCloseWatch(abs)
 	if err != nil {
-		return errors.Wrap(err, "open failed")
+		return l.retry(ctx, expired, clk, abs, true, try)
 	}
-	err = unix.FcntlFlock(file.Fd(), F_OFD_SETLK, &unix.Flock_t{
-		Type:   unix.F_WRLCK,
-		Whence: int16(io.SeekStart),
-	})
-	if err != nil {
-		if err == unix.EAGAIN || err == unix.EWOULDBLOCK {
-			err = ErrLockLocked
+	done, stop, stopped := make(chan error, 1), make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			done <- cancelled(ctx.Err())
+		case <-expired:
+			done <- ErrLockTimeout
+		case <-stop:
+			return
+		}
+		watch.interrupt()
+	}()
+	defer func() {
+		close(stop)
+		<-stopped
+		watch.close()
+	}()
+
+	for attempt := 1; ; attempt++ {
+		err := try()
+		if err == nil {
+			return nil
+		}
+		if err != ErrLockLocked {
+			return lockFailed(abs, err)
+		}
+		select {
+		case err := <-done:
+			return err
+		default:
 		}
+		l.mu.Lock()
+		interval, logger, hooks := l.retryInterval+jitter(l.jitter), l.labeledLogger(), l.hooks
+		l.mu.Unlock()
+
+		hooks.OnRetry(l.path, attempt)
+		// the interval bounds the wait for closes the watch can't notice, e.g.
+		// on remote file systems
+		logger.WithField("path", abs).Debugf("lock is locked, waiting up to %s for its release", interval)
+		watch.wait(interval)
+		select {
+		case err := <-done:
+			return err
+		default:
+		}
+	}
+}
+
+// lockFailed returns the error of a failed attempt to lock the file at abs.
+func lockFailed(abs string, err error) error {
+	var lerr *LockError
+	if err == ErrLockDeadlock || errors.As(err, &lerr) {
 		return err
 	}
-	l.path = abs
-	l.file = file
+	return &LockError{Op: "lock", Path: abs, Err: diagnose("lock", abs, err)}
+}
 
-	return nil
+// openFlags returns the flags and the permissions the file is opened with.
+// l.mu must be held.
+func (l *Locker) openFlags() (int, os.FileMode) {
+	flag := os.O_RDWR
+	if l.readOnly {
+		flag = os.O_RDONLY
+	}
+	flag |= l.flags
+	if l.create {
+		flag |= os.O_CREATE
+	}
+	return flag, l.perm
 }
 
-// todo:
-// Unlock ...
-func (l *Locker) Unlock() error {
-	// it's sufficient to simply close the file descriptor
-	if err := l.file.Close(); err != nil {
-		return errors.Wrap(err, "close failed")
+// open opens the file at the absolute path of the Locker for locking. If the
+// path is a directory, the lock file within the directory is opened and
+// created if it doesn't exist.
+func (l *Locker) open() (*handle, error) {
+	abs, err := filepath.Abs(l.path)
+	if err != nil {
+		return nil, fmt.Errorf("absolute represenation of path failed: %w", err)
 	}
-	return nil
+	l.mu.Lock()
+	fs, create, name, cleanup, ttl := l.fs, l.create, l.dirLockName, l.dirCleanup, l.lease
+	remove := l.removeOnUnlock
+	flag, perm := l.openFlags()
+	l.mu.Unlock()
+
+	fi, err := fs.Stat(abs)
+	switch {
+	case os.IsNotExist(err) && create:
+		// created by OpenFile
+	case os.IsNotExist(err):
+		return nil, &LockError{Op: "open", Path: abs, Err: ErrNotExist}
+	case err != nil:
+		return nil, fmt.Errorf("stat failed: %w", err)
+	case fi.IsDir():
+		abs = filepath.Join(abs, name)
+		flag |= os.O_CREATE
+		remove = remove || cleanup
+	}
+	file, err := fs.OpenFile(abs, flag, perm)
+	if err != nil {
+		return nil, openFailed(abs, err)
+	}
+	return &handle{fs: fs, file: file, path: abs, remove: remove, ttl: ttl}, nil
 }
@@ -0,0 +1,134 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// BackendFlock uses flock(2) locks. It's the backend of file based Lockers on
+// Darwin and the BSDs, which lack OFD locks. Like OFD locks, flock locks are
+// owned by the open file description: they are inherited across fork() and
+// only released when the last reference to the open file is closed.
+const BackendFlock Backend = "flock"
+
+const fileBackend = BackendFlock
+
+// errUnsupported is returned by operations lacking an implementation on the
+// platform.
+var errUnsupported = errors.New("operation isn't supported on " + runtime.GOOS)
+
+// lockHow returns the flock operation acquiring a lock in mode.
+func lockHow(mode lockMode) int {
+	if mode == modeShared {
+		return unix.LOCK_SH
+	}
+	return unix.LOCK_EX
+}
+
+// setLock acquires the lock of file in mode without blocking. flock(2) locks
+// whole files only.
+func setLock(fs FileSystem, file *os.File, mode lockMode, rg region) error {
+	if rg != (region{}) {
+		return fmt.Errorf("flock of byte range: %w", errUnsupported)
+	}
+	err := fs.Flock(file, lockHow(mode)|unix.LOCK_NB)
+	if err == unix.EWOULDBLOCK {
+		return ErrLockLocked
+	}
+	return err
+}
+
+// waitLock acquires the lock of file in mode, blocking in flock(2) until it's
+// free.
+func waitLock(fs FileSystem, file *os.File, mode lockMode, rg region) error {
+	if rg != (region{}) {
+		return fmt.Errorf("flock of byte range: %w", errUnsupported)
+	}
+	for {
+		err := fs.Flock(file, lockHow(mode))
+		if err != unix.EINTR {
+			return err
+		}
+	}
+}
+
+// convertLock fails, flock(2) releases the lock before converting it. Another
+// process may acquire the lock in between, the conversion isn't atomic.
+func convertLock(fs FileSystem, file *os.File, mode lockMode, rg region) error {
+	return fmt.Errorf("conversion of flock lock: %w", errUnsupported)
+}
+
+// probeLock checks that the lock of file can be queried. flock(2) can't query
+// a lock without acquiring it, the lock is held for a moment if it's free.
+func probeLock(fs FileSystem, file *os.File) error {
+	_, err := isLocked(fs, file)
+	return err
+}
+
+// isLocked reports whether the lock of file is held through another open file
+// description.
+func isLocked(fs FileSystem, file *os.File) (bool, error) {
+	// the exclusive lock conflicts with every lock held elsewhere
+	err := setLock(fs, file, modeExclusive, region{})
+	if err == ErrLockLocked {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return false, fs.Flock(file, unix.LOCK_UN)
+}
+
+// processAlive reports whether the process pid exists. Processes of other
+// users exist as well, signaling them just isn't permitted.
+func processAlive(pid int) bool {
+	err := unix.Kill(pid, 0)
+	return err == nil || err == unix.EPERM
+}
+
+// touchFile sets the access and modification times of file to t through its
+// descriptor, the file at its path may have been replaced.
+func touchFile(file *os.File, t time.Time) error {
+	tv := unix.NsecToTimeval(t.UnixNano())
+	return unix.Futimes(int(file.Fd()), []unix.Timeval{tv, tv})
+}
+
+// systemLockDir is the directory of the lock files of the system.
+const systemLockDir = "/run/lock"
+
+// writableDir reports whether dir is a directory the process can create files
+// in.
+func writableDir(dir string) bool {
+	fi, err := os.Stat(dir)
+	return err == nil && fi.IsDir() && unix.Access(dir, unix.W_OK|unix.X_OK) == nil
+}
+
+// privateTempDir returns the directory of the user within os.TempDir, creating
+// it if it doesn't exist. Anyone can create it in the shared temp directory,
+// it must be owned by the user and inaccessible to others.
+func privateTempDir() (string, error) {
+	dir := filepath.Join(os.TempDir(), "lock-"+strconv.Itoa(os.Getuid()))
+	if err := os.Mkdir(dir, 0700); err != nil && !os.IsExist(err) {
+		return "", fmt.Errorf("create lock directory failed: %w", err)
+	}
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return "", fmt.Errorf("stat lock directory failed: %w", err)
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !fi.IsDir() || fi.Mode().Perm() != 0700 || !ok || int(st.Uid) != os.Getuid() {
+		return "", fmt.Errorf("lock directory %s isn't private", dir)
+	}
+	return dir, nil
+}
@@ -0,0 +1,147 @@
+package lock
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const fileBackend = BackendOFD
+
+const (
+	// Open File Description Locks
+	//
+	// Usually record locks held by a process are released on *any* close and are
+	// not inherited across a fork().
+	// These cmd values will set locks that conflict with process-associated
+	// record  locks, but are "owned" by the open file description, not the
+	// process. This means that they are inherited across fork() like BSD (flock)
+	// locks, and they are only released automatically when the last reference to
+	// the the open file against which they were acquired is put.
+	//
+	// source /usr/include/bits/fcntl-linux.h
+	F_OFD_GETLK  = 36
+	F_OFD_SETLK  = 37
+	F_OFD_SETLKW = 38
+)
+
+// lockType returns the type of the record lock held in mode.
+func lockType(mode lockMode) int16 {
+	if mode == modeShared {
+		return unix.F_RDLCK
+	}
+	return unix.F_WRLCK
+}
+
+// setLock acquires the lock of region of file in mode without blocking. A lock
+// already held through file is converted to mode atomically.
+func setLock(fs FileSystem, file *os.File, mode lockMode, rg region) error {
+	err := fs.Fcntl(file, F_OFD_SETLK, &unix.Flock_t{
+		Type:   lockType(mode),
+		Whence: int16(io.SeekStart),
+		Start:  rg.offset,
+		Len:    rg.len,
+	})
+	if err == unix.EAGAIN || err == unix.EWOULDBLOCK {
+		return ErrLockLocked
+	}
+	if err == unix.EDEADLK {
+		return ErrLockDeadlock
+	}
+	return err
+}
+
+// waitLock acquires the lock of region of file in mode, blocking until it's
+// free.
+func waitLock(fs FileSystem, file *os.File, mode lockMode, rg region) error {
+	for {
+		err := fs.Fcntl(file, F_OFD_SETLKW, &unix.Flock_t{
+			Type:   lockType(mode),
+			Whence: int16(io.SeekStart),
+			Start:  rg.offset,
+			Len:    rg.len,
+		})
+		switch err {
+		case unix.EINTR:
+			continue
+		case unix.EDEADLK:
+			return ErrLockDeadlock
+		}
+		return err
+	}
+}
+
+// convertLock converts the lock of region held through file to mode without
+// blocking.
+func convertLock(fs FileSystem, file *os.File, mode lockMode, rg region) error {
+	return setLock(fs, file, mode, rg)
+}
+
+// probeLock checks that the lock of file can be queried, without acquiring it.
+func probeLock(fs FileSystem, file *os.File) error {
+	// querying a conflicting lock checks that the filesystem supports OFD
+	// locks
+	return fs.Fcntl(file, F_OFD_GETLK, &unix.Flock_t{
+		Type:   unix.F_WRLCK,
+		Whence: int16(io.SeekStart),
+	})
+}
+
+// isLocked reports whether the lock of file is held through another open file
+// description.
+func isLocked(fs FileSystem, file *os.File) (bool, error) {
+	lk := unix.Flock_t{Type: unix.F_WRLCK, Whence: int16(io.SeekStart)}
+	if err := fs.Fcntl(file, F_OFD_GETLK, &lk); err != nil {
+		return false, err
+	}
+	return lk.Type != unix.F_UNLCK, nil
+}
+
+// processAlive reports whether the process pid exists. Processes of other
+// users exist as well, signaling them just isn't permitted.
+func processAlive(pid int) bool {
+	err := unix.Kill(pid, 0)
+	return err == nil || err == unix.EPERM
+}
+
+// touchFile sets the access and modification times of file to t through its
+// descriptor, the file at its path may have been replaced.
+func touchFile(file *os.File, t time.Time) error {
+	tv := unix.NsecToTimeval(t.UnixNano())
+	return unix.Futimes(int(file.Fd()), []unix.Timeval{tv, tv})
+}
+
+// systemLockDir is the directory of the lock files of the system.
+const systemLockDir = "/run/lock"
+
+// writableDir reports whether dir is a directory the process can create files
+// in.
+func writableDir(dir string) bool {
+	fi, err := os.Stat(dir)
+	return err == nil && fi.IsDir() && unix.Access(dir, unix.W_OK|unix.X_OK) == nil
+}
+
+// privateTempDir returns the directory of the user within os.TempDir, creating
+// it if it doesn't exist. Anyone can create it in the shared temp directory,
+// it must be owned by the user and inaccessible to others.
+func privateTempDir() (string, error) {
+	dir := filepath.Join(os.TempDir(), "lock-"+strconv.Itoa(os.Getuid()))
+	if err := os.Mkdir(dir, 0700); err != nil && !os.IsExist(err) {
+		return "", fmt.Errorf("create lock directory failed: %w", err)
+	}
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return "", fmt.Errorf("stat lock directory failed: %w", err)
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !fi.IsDir() || fi.Mode().Perm() != 0700 || !ok || int(st.Uid) != os.Getuid() {
+		return "", fmt.Errorf("lock directory %s isn't private", dir)
+	}
+	return dir, nil
+}
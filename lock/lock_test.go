@@ -1,10 +1,21 @@
 package lock
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/peertechde/lib/clock"
 )
 
 func TestLock(t *testing.T) {
@@ -20,13 +31,13 @@ func TestLock(t *testing.T) {
 	}()
 
 	// lock file
-	lock := New(file.Name(), 0)
+	lock := New(file.Name())
 	if err := lock.Lock(); err != nil {
 		t.Fatal(err)
 	}
 
 	// try to lock a locked file
-	dupl := New(file.Name(), 0)
+	dupl := New(file.Name())
 	if err := dupl.TryLock(); err != ErrLockLocked {
 		t.Fatal(err)
 	}
@@ -42,13 +53,14 @@ func TestLock(t *testing.T) {
 	// block while `dupl` is locked
 	locked := make(chan bool, 1)
 	go func() {
-		blocker := New(file.Name(), 0)
+		blocker := New(file.Name())
 		if err := blocker.Lock(); err != nil {
-			t.Fatal(err)
+			t.Error(err)
+			return
 		}
 		locked <- true
 		if err := blocker.Unlock(); err != nil {
-			t.Fatal(err)
+			t.Error(err)
 		}
 	}()
 
@@ -70,3 +82,725 @@ func TestLock(t *testing.T) {
 		t.Errorf("blocker didn't unblock")
 	}
 }
+
+func TestLockPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "lock"), nil, 0660); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	lock := New("lock")
+	if lock.ResolvedPath() != "" {
+		t.Fatalf("expected empty resolved path, got %q", lock.ResolvedPath())
+	}
+	if err := lock.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	defer lock.Unlock()
+
+	if lock.Path() != "lock" {
+		t.Fatalf("expected path %q, got %q", "lock", lock.Path())
+	}
+	expected, err := filepath.Abs("lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock.ResolvedPath() != expected {
+		t.Fatalf("expected resolved path %q, got %q", expected, lock.ResolvedPath())
+	}
+}
+
+func TestLockAccessors(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	var nilLock *Locker
+	if nilLock.File() != nil {
+		t.Fatal("expected nil file on nil locker")
+	}
+
+	lock := New(file.Name())
+	if lock.RetryInterval() != defaultRetryInterval {
+		t.Fatalf("expected retry interval %s, got %s", defaultRetryInterval, lock.RetryInterval())
+	}
+	if lock.Backend() != BackendOFD {
+		t.Fatalf("expected backend %q, got %q", BackendOFD, lock.Backend())
+	}
+	if lock.File() != nil || !lock.HeldSince().IsZero() {
+		t.Fatal("expected no file and zero held since before acquisition")
+	}
+
+	before := time.Now()
+	if err := lock.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if lock.File() == nil {
+		t.Fatal("expected file while locked")
+	}
+	if lock.HeldSince().Before(before) {
+		t.Fatalf("expected held since after %s, got %s", before, lock.HeldSince())
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if lock.File() != nil || !lock.HeldSince().IsZero() {
+		t.Fatal("expected no file and zero held since after release")
+	}
+}
+
+func TestLockConfigure(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	holder := New(file.Name())
+	if err := holder.Lock(); err != nil {
+		t.Fatal(err)
+	}
+
+	waiter := New(file.Name(), WithRetryInterval(10*time.Millisecond))
+	locked := make(chan error, 1)
+	go func() {
+		locked <- waiter.Lock()
+	}()
+
+	// reconfigure while the waiter is retrying
+	waiter.Configure(WithRetryInterval(20 * time.Millisecond))
+	if waiter.RetryInterval() != 20*time.Millisecond {
+		t.Fatalf("expected retry interval %s, got %s", 20*time.Millisecond, waiter.RetryInterval())
+	}
+	waiter.Configure(WithRetryInterval(0))
+	if waiter.RetryInterval() != defaultRetryInterval {
+		t.Fatalf("expected retry interval %s, got %s", defaultRetryInterval, waiter.RetryInterval())
+	}
+
+	if err := holder.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-locked:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("waiter didn't acquire the lock")
+	}
+	if err := waiter.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockClock(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	fake := clock.NewFake(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	holder := New(file.Name())
+	holder.Configure(WithClock(fake))
+	if err := holder.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if !holder.HeldSince().Equal(fake.Now()) {
+		t.Fatalf("expected held since %s, got %s", fake.Now(), holder.HeldSince())
+	}
+
+	waiter := New(file.Name(), WithRetryInterval(time.Second))
+	waiter.Configure(WithClock(fake), WithTimeout(time.Minute), WithPolling(true))
+	locked := make(chan error, 1)
+	go func() {
+		locked <- waiter.Lock()
+	}()
+
+	// the timeout and the retry timer are pending while the waiter waits
+	for fake.Timers() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+	fake.Advance(time.Minute)
+	select {
+	case err := <-locked:
+		if err != ErrLockTimeout {
+			t.Fatalf("expected %v, got %v", ErrLockTimeout, err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("waiter didn't time out")
+	}
+
+	// handoff deadlines
+	if err := holder.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	g, err := holder.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.RequestHandoff(time.Second)
+	fake.Advance(time.Second)
+	select {
+	case <-g.Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("guard wasn't released after the handoff deadline")
+	}
+}
+
+func TestLockLabels(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	var buf bytes.Buffer
+	logger := logrus.New()
+	logger.Out = &buf
+	logger.SetLevel(logrus.DebugLevel)
+
+	lock := New(file.Name(), WithRetryInterval(10*time.Millisecond))
+	lock.Configure(WithLogger(logger), WithLabels("job", "compaction"), WithLabels("shard", "3"))
+	labels := lock.Labels()
+	if len(labels) != 2 || labels["job"] != "compaction" || labels["shard"] != "3" {
+		t.Fatalf("unexpected labels %v", labels)
+	}
+	labels["job"] = "changed"
+	if lock.Labels()["job"] != "compaction" {
+		t.Fatal("expected labels to be copied")
+	}
+
+	holder := New(file.Name())
+	if err := holder.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	lock.Configure(WithTimeout(30 * time.Millisecond))
+	if err := lock.Lock(); err != ErrLockTimeout {
+		t.Fatalf("expected %v, got %v", ErrLockTimeout, err)
+	}
+	holder.Unlock()
+	if !bytes.Contains(buf.Bytes(), []byte("job=compaction")) || !bytes.Contains(buf.Bytes(), []byte("shard=3")) {
+		t.Fatalf("expected labels in log, got %q", buf.String())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected odd number of label arguments to panic")
+		}
+	}()
+	WithLabels("job")
+}
+
+func TestLockContext(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	holder := New(file.Name())
+	if err := holder.LockContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	waiter := New(file.Name(), WithRetryInterval(10*time.Millisecond))
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	err = waiter.LockContext(ctx)
+	if !errors.Is(err, ErrLockCancelled) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected cancelled acquisition, got %v", err)
+	}
+	if err := waiter.TryLockContext(context.Background()); err != ErrLockLocked {
+		t.Fatalf("expected %v, got %v", ErrLockLocked, err)
+	}
+
+	if err := holder.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := waiter.TryLockContext(ctx); !errors.Is(err, ErrLockCancelled) {
+		t.Fatalf("expected done context to cancel, got %v", err)
+	}
+	if err := waiter.TryLockContext(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := waiter.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockGoroutines(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	// goroutines sharing a Locker and goroutines with Lockers of their own
+	// exclude each other
+	shared := New(file.Name())
+	var inside int32
+	errs := make(chan error, 16)
+	for i := 0; i < cap(errs); i++ {
+		l := shared
+		if i%2 == 0 {
+			l = New(file.Name())
+		}
+		go func() {
+			for j := 0; j < 10; j++ {
+				if err := l.Lock(); err != nil {
+					errs <- err
+					return
+				}
+				if n := atomic.AddInt32(&inside, 1); n != 1 {
+					errs <- fmt.Errorf("%d holders at once", n)
+					return
+				}
+				atomic.AddInt32(&inside, -1)
+				if err := l.Unlock(); err != nil {
+					errs <- err
+					return
+				}
+			}
+			errs <- nil
+		}()
+	}
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if shared.File() != nil || !shared.HeldSince().IsZero() {
+		t.Fatal("expected shared Locker to be unlocked")
+	}
+}
+
+func TestTryLockFor(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	holder := New(file.Name())
+	if err := holder.Lock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the deadline applies regardless of the timeout of the Locker
+	waiter := New(file.Name(), WithTimeout(time.Hour))
+	start := time.Now()
+	if err := waiter.TryLockFor(50 * time.Millisecond); err != ErrLockTimeout {
+		t.Fatalf("expected %v, got %v", ErrLockTimeout, err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("expected TryLockFor to retry for 50ms, gave up after %s", elapsed)
+	}
+	if err := waiter.TryLockFor(0); err != ErrLockLocked {
+		t.Fatalf("expected %v, got %v", ErrLockLocked, err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- waiter.TryLockFor(time.Second)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if err := holder.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := waiter.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockState(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	var nilLocker *Locker
+	if err := nilLocker.Unlock(); err != ErrNotLocked || nilLocker.IsLocked() {
+		t.Fatalf("expected nil Locker not to be locked, got %v", err)
+	}
+	l := New(file.Name())
+	if err := l.Unlock(); err != ErrNotLocked {
+		t.Fatalf("expected %v, got %v", ErrNotLocked, err)
+	}
+
+	// the Locker is reused after Unlock
+	for i := 0; i < 3; i++ {
+		if err := l.Lock(); err != nil {
+			t.Fatal(err)
+		}
+		if !l.IsLocked() {
+			t.Fatal("expected Locker to be locked")
+		}
+		if err := l.Unlock(); err != nil {
+			t.Fatal(err)
+		}
+		if err := l.Unlock(); err != ErrNotLocked {
+			t.Fatalf("expected %v, got %v", ErrNotLocked, err)
+		}
+		if l.IsLocked() || l.File() != nil {
+			t.Fatal("expected Locker to be unlocked")
+		}
+	}
+	other := New(file.Name())
+	if err := other.TryLock(); err != nil {
+		t.Fatalf("expected lock to be free, got %v", err)
+	}
+	other.Unlock()
+}
+
+func TestLockAlreadyLocked(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	// shared locks don't exclude each other, the Locker holds one though
+	l := New(file.Name())
+	if err := l.RLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.TryRLock(); err != ErrAlreadyLocked {
+		t.Fatalf("expected %v, got %v", ErrAlreadyLocked, err)
+	}
+	if err := l.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	other := New(file.Name())
+	if err := other.TryLock(); err != nil {
+		t.Fatalf("expected lock to be free, got %v", err)
+	}
+	other.Unlock()
+}
+
+func TestLockUnlockRace(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	l := New(file.Name())
+	for i := 0; i < 10; i++ {
+		if err := l.Lock(); err != nil {
+			t.Fatal(err)
+		}
+		// exactly one of the concurrent unlocks releases the lock
+		var released int32
+		errs := make(chan error, 4)
+		for j := 0; j < cap(errs); j++ {
+			go func() {
+				err := l.Unlock()
+				switch err {
+				case nil:
+					atomic.AddInt32(&released, 1)
+				case ErrNotLocked:
+					err = nil
+				}
+				errs <- err
+			}()
+		}
+		for j := 0; j < cap(errs); j++ {
+			if err := <-errs; err != nil {
+				t.Fatal(err)
+			}
+		}
+		if released != 1 {
+			t.Fatalf("expected a single release, got %d", released)
+		}
+	}
+}
+
+func TestLockBlocking(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	holder := New(file.Name())
+	if err := holder.Lock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the blocked waiter acquires the lock right after the release, the
+	// polling waiter only after its retry interval
+	waiter, poller := New(file.Name(), WithRetryInterval(time.Hour)), New(file.Name(), WithRetryInterval(time.Hour))
+	poller.Configure(WithPolling(true), WithTimeout(time.Second))
+	locked := make(chan error, 2)
+	for _, l := range []*Locker{waiter, poller} {
+		go func(l *Locker) {
+			locked <- l.Lock()
+		}(l)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := holder.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-locked:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("waiter didn't acquire the lock")
+	}
+	if waiter.File() == nil {
+		t.Fatal("expected blocked waiter to acquire the lock")
+	}
+
+	// a cancellable waiter notices the release through the closing of the
+	// file, long before its retry interval
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	acquired := make(chan error, 1)
+	go func() {
+		acquired <- holder.LockContext(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if err := waiter.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("cancellable waiter didn't acquire the lock")
+	}
+
+	// a cancelled waiter leaves the lock free once it's released
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := waiter.LockContext(ctx); !errors.Is(err, ErrLockCancelled) {
+		t.Fatalf("expected %v, got %v", ErrLockCancelled, err)
+	}
+	if err := holder.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := waiter.TryLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := waiter.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockOpenOptions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "lock")
+
+	if err := New(path).TryLock(); err == nil {
+		t.Fatal("expected missing file to fail")
+	}
+	created := New(path, WithCreate(0600))
+	if err := created.TryLock(); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("expected mode 0600, got %s", fi.Mode().Perm())
+	}
+	if err := created.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// read-only Lockers are limited to shared locks
+	reader := New(path, WithReadOnly())
+	if err := reader.TryLock(); err == nil {
+		t.Fatal("expected exclusive lock of read-only Locker to fail")
+	}
+	if err := reader.TryRLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := reader.Upgrade(); err == nil {
+		t.Fatal("expected upgrade of read-only Locker to fail")
+	}
+	if err := reader.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := New(path, WithCreate(0600), WithOpenFlags(os.O_EXCL)).TryLock(); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected O_EXCL to refuse the existing file, got %v", err)
+	}
+}
+
+func TestLockDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	holder := New(dir)
+	if err := holder.TryLock(); err != nil {
+		t.Fatal(err)
+	}
+	if holder.ResolvedPath() != filepath.Join(dir, ".lock") {
+		t.Fatalf("expected lock file in directory, got %q", holder.ResolvedPath())
+	}
+	if err := New(dir).TryLock(); err != ErrLockLocked {
+		t.Fatalf("expected %v, got %v", ErrLockLocked, err)
+	}
+	if err := New(filepath.Join(dir, ".lock")).TryLock(); err != ErrLockLocked {
+		t.Fatalf("expected lock file to be locked, got %v", err)
+	}
+	named := New(dir, WithDirLockName("app.lock"))
+	if err := named.TryLock(); err != nil {
+		t.Fatalf("expected lock files to be independent, got %v", err)
+	}
+	named.Unlock()
+	if err := holder.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".lock")); err != nil {
+		t.Fatalf("expected lock file to be kept, got %v", err)
+	}
+
+	// the last of the shared holders removes the lock file
+	r1, r2 := New(dir, WithDirCleanup(true)), New(dir, WithDirCleanup(true))
+	if err := r1.TryRLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r2.TryRLock(); err != nil {
+		t.Fatal(err)
+	}
+	r1.Unlock()
+	if _, err := os.Stat(filepath.Join(dir, ".lock")); err != nil {
+		t.Fatalf("expected lock file to be kept while shared, got %v", err)
+	}
+	r2.Unlock()
+	if _, err := os.Stat(filepath.Join(dir, ".lock")); !os.IsNotExist(err) {
+		t.Fatalf("expected lock file to be removed, got %v", err)
+	}
+
+	// a waiter blocked on the removed file locks the recreated one
+	holder.Configure(WithDirCleanup(true))
+	waiter := New(dir, WithDirCleanup(true))
+	if err := holder.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	locked := make(chan error, 1)
+	go func() {
+		locked <- waiter.Lock()
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if err := holder.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-locked; err != nil {
+		t.Fatal(err)
+	}
+	if err := New(dir).TryLock(); err != ErrLockLocked {
+		t.Fatalf("expected recreated lock file to be locked, got %v", err)
+	}
+	if err := waiter.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockRemoveOnUnlock(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("open files can't be removed on windows")
+	}
+	dir, err := ioutil.TempDir("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.lock")
+
+	holder := New(path, WithRemoveOnUnlock())
+	if err := holder.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected lock file to be created, got %v", err)
+	}
+
+	// a waiter blocked on the removed file locks the recreated one
+	waiter := New(path, WithRemoveOnUnlock())
+	locked := make(chan error, 1)
+	go func() {
+		locked <- waiter.Lock()
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if err := holder.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-locked; err != nil {
+		t.Fatal(err)
+	}
+	if err := New(path).TryLock(); err != ErrLockLocked {
+		t.Fatalf("expected recreated lock file to be locked, got %v", err)
+	}
+	if err := waiter.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected lock file to be removed, got %v", err)
+	}
+
+	if holder.Backend() == "flock" {
+		return
+	}
+	// only the last holder of a shared lock removes the file
+	r1, r2 := New(path, WithRemoveOnUnlock()), New(path, WithRemoveOnUnlock())
+	if err := r1.RLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r2.RLock(); err != nil {
+		t.Fatal(err)
+	}
+	r1.Unlock()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected lock file to be kept while held, got %v", err)
+	}
+	r2.Unlock()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected lock file to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// BackendLockFileEx uses the byte range locks of LockFileEx. It's the backend
+// of file based Lockers on Windows. The locks are mandatory, other handles
+// can't write the file while it's locked.
+const BackendLockFileEx Backend = "lockfileex"
+
+const fileBackend = BackendLockFileEx
+
+// errUnsupported is returned by operations lacking an implementation on
+// Windows.
+var errUnsupported = errors.New("operation isn't supported on windows")
+
+// lockFlags returns the flags of LockFileEx acquiring a lock in mode.
+func lockFlags(mode lockMode) uint32 {
+	if mode == modeShared {
+		return 0
+	}
+	return windows.LOCKFILE_EXCLUSIVE_LOCK
+}
+
+// overlapped returns the Overlapped and the length of rg as passed to
+// LockFileEx. A zero length locks up to the largest offset.
+func overlapped(rg region) (ol *windows.Overlapped, low, high uint32) {
+	ol = &windows.Overlapped{Offset: uint32(rg.offset), OffsetHigh: uint32(rg.offset >> 32)}
+	if rg.len == 0 {
+		return ol, ^uint32(0), ^uint32(0)
+	}
+	return ol, uint32(rg.len), uint32(rg.len >> 32)
+}
+
+// setLock acquires the lock of region of file in mode without blocking.
+func setLock(fs FileSystem, file *os.File, mode lockMode, rg region) error {
+	ol, low, high := overlapped(rg)
+	err := fs.LockFileEx(file, lockFlags(mode)|windows.LOCKFILE_FAIL_IMMEDIATELY, low, high, ol)
+	if err == windows.ERROR_LOCK_VIOLATION {
+		return ErrLockLocked
+	}
+	return err
+}
+
+// waitLock acquires the lock of region of file in mode, blocking until it's
+// granted. If the handle was opened for overlapped I/O, the pending operation
+// is awaited through the event of an Overlapped.
+func waitLock(fs FileSystem, file *os.File, mode lockMode, rg region) error {
+	event, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		return fmt.Errorf("create event failed: %w", err)
+	}
+	defer windows.CloseHandle(event)
+
+	ol, low, high := overlapped(rg)
+	ol.HEvent = event
+	err = fs.LockFileEx(file, lockFlags(mode), low, high, ol)
+	if err == windows.ERROR_IO_PENDING {
+		var n uint32
+		err = windows.GetOverlappedResult(windows.Handle(file.Fd()), ol, &n, true)
+	}
+	return err
+}
+
+// convertLock converts the lock of region held through file to mode without
+// blocking.
+// Shared locks can't be upgraded atomically on Windows, an exclusive lock may
+// not overlap the shared lock of the same handle.
+func convertLock(fs FileSystem, file *os.File, mode lockMode, rg region) error {
+	if mode == modeExclusive {
+		return fmt.Errorf("upgrade of shared lock: %w", errUnsupported)
+	}
+	// the shared lock overlaps the exclusive lock of the same handle, the
+	// first unlock releases the exclusive lock
+	if err := setLock(fs, file, modeShared, rg); err != nil {
+		return err
+	}
+	ol, low, high := overlapped(rg)
+	return fs.UnlockFileEx(file, low, high, ol)
+}
+
+// probeLock checks that the lock of file can be queried. Windows can't query
+// a lock without acquiring it, the lock is held for a moment if it's free.
+func probeLock(fs FileSystem, file *os.File) error {
+	_, err := isLocked(fs, file)
+	return err
+}
+
+// isLocked reports whether the lock of file is held through another handle.
+func isLocked(fs FileSystem, file *os.File) (bool, error) {
+	// the exclusive lock conflicts with every lock held elsewhere
+	err := setLock(fs, file, modeExclusive, region{})
+	if err == ErrLockLocked {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	ol, low, high := overlapped(region{})
+	return false, fs.UnlockFileEx(file, low, high, ol)
+}
+
+// stillActive is the exit code of processes which haven't exited.
+const stillActive = 259
+
+// processAlive reports whether the process pid exists. Processes which can't
+// be queried for lack of permission exist as well.
+func processAlive(pid int) bool {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err == windows.ERROR_ACCESS_DENIED {
+		return true
+	}
+	if err != nil {
+		return false
+	}
+	defer windows.CloseHandle(h)
+
+	var code uint32
+	if err := windows.GetExitCodeProcess(h, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
+
+// touchFile sets the access and modification times of file to t. Open files
+// can't be replaced on Windows, its path still refers to it.
+func touchFile(file *os.File, t time.Time) error {
+	return os.Chtimes(file.Name(), t, t)
+}
+
+// systemLockDir is empty, Windows has no lock directory of the system.
+const systemLockDir = ""
+
+// writableDir reports whether dir is a directory.
+func writableDir(dir string) bool {
+	fi, err := os.Stat(dir)
+	return err == nil && fi.IsDir()
+}
+
+// privateTempDir returns os.TempDir, which belongs to the user on Windows.
+func privateTempDir() (string, error) {
+	return os.TempDir(), nil
+}
@@ -0,0 +1,62 @@
+package lock
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLockFileEx(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	l1, l2 := New(file.Name()), New(file.Name())
+	if l1.Backend() != BackendLockFileEx {
+		t.Fatalf("expected backend %q, got %q", BackendLockFileEx, l1.Backend())
+	}
+	if err := l1.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l2.TryLock(); err != ErrLockLocked {
+		t.Fatalf("expected %v, got %v", ErrLockLocked, err)
+	}
+
+	// Lock blocks in LockFileEx until the lock is released
+	done := make(chan error, 1)
+	go func() {
+		done <- l2.Lock()
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("acquired held lock: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := l1.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// the exclusive lock is downgraded, upgrades aren't supported
+	if err := l2.Downgrade(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l1.TryRLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l2.Upgrade(); err == nil {
+		t.Fatal("expected upgrade to fail")
+	}
+	if err := l1.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l2.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,387 @@
+// Package lockconform grades lock implementations against the guarantees of
+// the lock package.
+//
+// An implementation is graded for each of the following capabilities:
+//
+//	capability      guarantee
+//	exclusion       distinct owners exclude each other within one process
+//	process         owners in distinct processes exclude each other
+//	crash-release   the lock is released when the holding process dies
+//	fairness        blocked owners acquire the lock in the order they blocked
+//	shared          the implementation provides RLock and TryRLock
+//	cancellation    the implementation provides Acquire(context.Context)
+//
+// The process and crash-release capabilities are graded by running owners in a
+// helper process. Test binaries enable them by calling RunHelper from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		lockconform.RunHelper(subject)
+//		os.Exit(m.Run())
+//	}
+//
+// Grade returns a machine-readable Report, Run fails a test unless the
+// required capabilities are provided.
+package lockconform
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peertechde/lib/lock"
+)
+
+// Capability is a guarantee a lock implementation may provide.
+type Capability string
+
+const (
+	Exclusion    Capability = "exclusion"
+	Process      Capability = "process"
+	CrashRelease Capability = "crash-release"
+	Fairness     Capability = "fairness"
+	Shared       Capability = "shared"
+	Cancellation Capability = "cancellation"
+)
+
+// Capabilities lists all capabilities in the order they are graded.
+var Capabilities = []Capability{Exclusion, Process, CrashRelease, Fairness, Shared, Cancellation}
+
+const (
+	helperEnv   = "LOCKCONFORM_HELPER"
+	helperReady = "locked"
+
+	// timeout bounds every wait for the implementation
+	timeout = 5 * time.Second
+
+	// blocked is how long an owner has to stay blocked to count as blocked
+	blocked = 50 * time.Millisecond
+)
+
+// Subject is a lock implementation under test.
+type Subject struct {
+	// Name identifies the implementation in the Report.
+	Name string
+
+	// New returns a new owner of the lock identified by key. The key is the
+	// path of an existing, empty file which implementations not backed by
+	// files treat as an opaque name.
+	New func(key string) lock.Interface
+}
+
+// Result is the grade of a single capability.
+type Result struct {
+	Capability Capability `json:"capability"`
+	Supported  bool       `json:"supported"`
+
+	// Tested is false if the capability couldn't be graded, e.g. because the
+	// helper process isn't enabled.
+	Tested bool   `json:"tested"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Report is the capability report of a Subject.
+type Report struct {
+	Name    string   `json:"name"`
+	Results []Result `json:"results"`
+}
+
+// Supports reports whether the Subject provides c.
+func (r *Report) Supports(c Capability) bool {
+	for _, result := range r.Results {
+		if result.Capability == c {
+			return result.Supported
+		}
+	}
+	return false
+}
+
+// JSON returns the indented JSON encoding of the Report.
+func (r *Report) JSON() []byte {
+	data, _ := json.MarshalIndent(r, "", "  ")
+	return data
+}
+
+// Run grades s and fails t if one of the required capabilities isn't
+// provided. The Report is logged as JSON.
+func Run(t *testing.T, s Subject, required ...Capability) *Report {
+	t.Helper()
+
+	report, err := Grade(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%s", report.JSON())
+	for _, c := range required {
+		if !report.Supports(c) {
+			t.Errorf("%s doesn't provide required capability %q", s.Name, c)
+		}
+	}
+	return report
+}
+
+// Grade grades every capability of s.
+func Grade(s Subject) (*Report, error) {
+	dir, err := ioutil.TempDir("", "lockconform")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	graders := map[Capability]func(Subject, string) Result{
+		Exclusion:    gradeExclusion,
+		Process:      gradeProcess,
+		CrashRelease: gradeCrashRelease,
+		Fairness:     gradeFairness,
+		Shared:       gradeShared,
+		Cancellation: gradeCancellation,
+	}
+	report := &Report{Name: s.Name}
+	for i, c := range Capabilities {
+		key := filepath.Join(dir, fmt.Sprintf("lock-%d", i))
+		if err := ioutil.WriteFile(key, nil, 0660); err != nil {
+			return nil, err
+		}
+		result := graders[c](s, key)
+		result.Capability = c
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+// RunHelper performs the operation requested by a grading process and exits if
+// the process was started as a helper. Otherwise it returns immediately.
+func RunHelper(s Subject) {
+	key := os.Getenv(helperEnv)
+	if key == "" {
+		return
+	}
+	owner := s.New(key)
+	if err := owner.Lock(); err != nil {
+		fmt.Fprintf(os.Stderr, "lockconform: lock failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(helperReady)
+
+	// hold the lock until the grading process closes stdin or kills us
+	ioutil.ReadAll(os.Stdin)
+	owner.Unlock()
+	os.Exit(0)
+}
+
+func supported(detail string, args ...interface{}) Result {
+	return Result{Supported: true, Tested: true, Detail: fmt.Sprintf(detail, args...)}
+}
+
+func unsupported(detail string, args ...interface{}) Result {
+	return Result{Tested: true, Detail: fmt.Sprintf(detail, args...)}
+}
+
+func untested(detail string, args ...interface{}) Result {
+	return Result{Detail: fmt.Sprintf(detail, args...)}
+}
+
+func gradeExclusion(s Subject, key string) Result {
+	a, b := s.New(key), s.New(key)
+	if err := a.Lock(); err != nil {
+		return unsupported("lock failed: %v", err)
+	}
+	if err := b.TryLock(); !errors.Is(err, lock.ErrLockLocked) {
+		if err == nil {
+			b.Unlock()
+		}
+		a.Unlock()
+		return unsupported("TryLock of held lock returned %v", err)
+	}
+	if err := a.Unlock(); err != nil {
+		return unsupported("unlock failed: %v", err)
+	}
+	if err := b.TryLock(); err != nil {
+		return unsupported("TryLock of released lock failed: %v", err)
+	}
+	b.Unlock()
+	return supported("")
+}
+
+// helper is an owner of the lock in a helper process.
+type helper struct {
+	cmd   *exec.Cmd
+	stdin interface{ Close() error }
+}
+
+// startHelper starts a helper process holding the lock identified by key.
+func startHelper(key string) (*helper, Result, bool) {
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(), helperEnv+"="+key)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, untested("helper failed: %v", err), false
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, untested("helper failed: %v", err), false
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, untested("helper failed: %v", err), false
+	}
+	ready := make(chan bool, 1)
+	go func() {
+		line, _ := bufio.NewReader(stdout).ReadString('\n')
+		ready <- strings.TrimSpace(line) == helperReady
+	}()
+	select {
+	case ok := <-ready:
+		if ok {
+			return &helper{cmd: cmd, stdin: stdin}, Result{}, true
+		}
+	case <-time.After(timeout):
+	}
+	cmd.Process.Kill()
+	cmd.Wait()
+	return nil, untested("helper process isn't enabled, call RunHelper from TestMain"), false
+}
+
+func gradeProcess(s Subject, key string) Result {
+	h, result, ok := startHelper(key)
+	if !ok {
+		return result
+	}
+	defer func() {
+		h.stdin.Close()
+		h.cmd.Wait()
+	}()
+
+	owner := s.New(key)
+	if err := owner.TryLock(); !errors.Is(err, lock.ErrLockLocked) {
+		if err == nil {
+			owner.Unlock()
+		}
+		return unsupported("TryLock of lock held by another process returned %v", err)
+	}
+	return supported("")
+}
+
+func gradeCrashRelease(s Subject, key string) Result {
+	h, result, ok := startHelper(key)
+	if !ok {
+		return result
+	}
+	h.cmd.Process.Kill()
+	h.cmd.Wait()
+
+	owner := s.New(key)
+	deadline := time.Now().Add(timeout)
+	for {
+		err := owner.TryLock()
+		if err == nil {
+			owner.Unlock()
+			return supported("")
+		}
+		if time.Now().After(deadline) {
+			return unsupported("lock still held %s after the holder died: %v", timeout, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func gradeFairness(s Subject, key string) Result {
+	const waiters = 4
+
+	holder := s.New(key)
+	if err := holder.Lock(); err != nil {
+		return unsupported("lock failed: %v", err)
+	}
+	acquired := make(chan int, waiters)
+	for i := 0; i < waiters; i++ {
+		owner := s.New(key)
+		go func(i int) {
+			if err := owner.Lock(); err != nil {
+				acquired <- -1
+				return
+			}
+			acquired <- i
+			owner.Unlock()
+		}(i)
+		// give the waiter time to block before the next one arrives
+		time.Sleep(blocked)
+	}
+	if err := holder.Unlock(); err != nil {
+		return unsupported("unlock failed: %v", err)
+	}
+
+	var order []int
+	for len(order) < waiters {
+		select {
+		case i := <-acquired:
+			if i < 0 {
+				return unsupported("lock of waiter failed")
+			}
+			order = append(order, i)
+		case <-time.After(timeout):
+			return unsupported("waiters didn't acquire the lock within %s", timeout)
+		}
+	}
+	for i := range order {
+		if order[i] != i {
+			return unsupported("waiters acquired the lock in order %v", order)
+		}
+	}
+	return supported("waiters acquired the lock in order %v", order)
+}
+
+func gradeShared(s Subject, key string) Result {
+	type shared interface {
+		RLock() error
+		TryRLock() error
+	}
+	if _, ok := s.New(key).(shared); !ok {
+		return unsupported("RLock and TryRLock aren't implemented")
+	}
+	a, b := s.New(key).(shared), s.New(key).(shared)
+	if err := a.RLock(); err != nil {
+		return unsupported("RLock failed: %v", err)
+	}
+	defer a.(lock.Interface).Unlock()
+	if err := b.TryRLock(); err != nil {
+		return unsupported("second shared owner failed: %v", err)
+	}
+	b.(lock.Interface).Unlock()
+	return supported("")
+}
+
+func gradeCancellation(s Subject, key string) Result {
+	type acquirer interface {
+		Acquire(ctx context.Context) (*lock.Guard, error)
+	}
+	holder := s.New(key)
+	waiter, ok := s.New(key).(acquirer)
+	if !ok {
+		return unsupported("Acquire isn't implemented")
+	}
+	if err := holder.Lock(); err != nil {
+		return unsupported("lock failed: %v", err)
+	}
+	defer holder.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), blocked)
+	defer cancel()
+	start := time.Now()
+	g, err := waiter.Acquire(ctx)
+	if err == nil {
+		g.Release()
+		return unsupported("Acquire of held lock succeeded")
+	}
+	if waited := time.Since(start); waited > timeout {
+		return unsupported("Acquire returned %s after cancellation", waited)
+	}
+	return supported("")
+}
@@ -0,0 +1,46 @@
+package lockconform
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/peertechde/lib/lock"
+)
+
+var subject = Subject{
+	Name: "ofd",
+	New: func(key string) lock.Interface {
+		return lock.New(key, lock.WithRetryInterval(time.Millisecond))
+	},
+}
+
+func TestMain(m *testing.M) {
+	RunHelper(subject)
+	os.Exit(m.Run())
+}
+
+func TestLocker(t *testing.T) {
+	report := Run(t, subject, Exclusion, Process, CrashRelease, Cancellation)
+	if len(report.Results) != len(Capabilities) {
+		t.Fatalf("expected %d results, got %d", len(Capabilities), len(report.Results))
+	}
+	for _, result := range report.Results {
+		if !result.Tested {
+			t.Errorf("capability %q wasn't tested: %s", result.Capability, result.Detail)
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	memory := lock.NewMemory()
+	report := Run(t, Subject{
+		Name: "memory",
+		New: func(key string) lock.Interface {
+			return memory.Locker(key, 0)
+		},
+	}, Exclusion)
+	if report.Supports(Process) {
+		t.Fatal("expected memory locks not to exclude other processes")
+	}
+}
@@ -0,0 +1,97 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// LockSet locks several files together. The locks are acquired in the order
+// of their absolute paths, so LockSets of overlapping files can't deadlock
+// each other, and released in reverse order.
+type LockSet struct {
+	lockers []*Locker
+}
+
+// NewLockSet returns a LockSet of the files at paths, each locked by a Locker
+// configured by opts. Paths referring to the same file by the same absolute
+// path are locked once.
+func NewLockSet(paths []string, opts ...Option) (*LockSet, error) {
+	abs := make([]string, 0, len(paths))
+	seen := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		p, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("absolute represenation of path failed: %w", err)
+		}
+		if !seen[p] {
+			seen[p] = true
+			abs = append(abs, p)
+		}
+	}
+	sort.Strings(abs)
+
+	s := &LockSet{lockers: make([]*Locker, len(abs))}
+	for i, p := range abs {
+		s.lockers[i] = New(p, opts...)
+	}
+	return s, nil
+}
+
+// Paths returns the absolute paths of the LockSet in the order of acquisition.
+func (s *LockSet) Paths() []string {
+	paths := make([]string, len(s.lockers))
+	for i, l := range s.lockers {
+		paths[i] = l.Path()
+	}
+	return paths
+}
+
+// LockAll locks every file of the LockSet, blocking like Lock. If a lock
+// can't be acquired, the locks acquired so far are released and the error is
+// returned.
+func (s *LockSet) LockAll() error {
+	return s.LockAllContext(context.Background())
+}
+
+// LockAllContext locks like LockAll but gives up once ctx is done, see
+// LockContext.
+func (s *LockSet) LockAllContext(ctx context.Context) error {
+	return s.acquire(func(l *Locker) error { return l.LockContext(ctx) })
+}
+
+// TryLockAll locks every file of the LockSet without blocking, all or nothing.
+// It returns ErrLockLocked and holds none of the locks if any of them is held
+// elsewhere.
+func (s *LockSet) TryLockAll() error {
+	return s.acquire((*Locker).TryLock)
+}
+
+func (s *LockSet) acquire(lock func(l *Locker) error) error {
+	for i, l := range s.lockers {
+		if err := lock(l); err != nil {
+			// roll the partial acquisition back
+			s.release(s.lockers[:i])
+			return err
+		}
+	}
+	return nil
+}
+
+// Unlock releases the locks of the LockSet in reverse order of acquisition.
+// Every lock is released even if releasing another one failed, the first
+// error is returned.
+func (s *LockSet) Unlock() error {
+	return s.release(s.lockers)
+}
+
+func (s *LockSet) release(lockers []*Locker) error {
+	var first error
+	for i := len(lockers) - 1; i >= 0; i-- {
+		if err := lockers[i].Unlock(); err != nil && first == nil {
+			first = fmt.Errorf("unlock %s failed: %w", lockers[i].Path(), err)
+		}
+	}
+	return first
+}
@@ -0,0 +1,98 @@
+package lock
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestLockSet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var paths []string
+	for _, name := range []string{"journal", "config", "data"} {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	s1, err := NewLockSet(append(paths, paths[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s1.Paths(); len(got) != 3 || !sort.StringsAreSorted(got) {
+		t.Fatalf("expected sorted unique paths, got %v", got)
+	}
+	if err := s1.LockAll(); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		if err := New(path).TryLock(); err != ErrLockLocked {
+			t.Fatalf("expected %s to be locked, got %v", path, err)
+		}
+	}
+	if err := s1.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// TryLockAll rolls back the locks acquired before the held one
+	held := New(paths[0])
+	if err := held.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s1.TryLockAll(); err != ErrLockLocked {
+		t.Fatalf("expected %v, got %v", ErrLockLocked, err)
+	}
+	for _, path := range paths[1:] {
+		l := New(path)
+		if err := l.TryLock(); err != nil {
+			t.Fatalf("expected %s to be released, got %v", path, err)
+		}
+		l.Unlock()
+	}
+
+	// sets of the same files in different order don't deadlock
+	s2, err := NewLockSet([]string{paths[2], paths[1], paths[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 2)
+	for _, s := range []*LockSet{s1, s2} {
+		go func(s *LockSet) {
+			for i := 0; i < 10; i++ {
+				if err := s.LockAll(); err != nil {
+					done <- err
+					return
+				}
+				if err := s.Unlock(); err != nil {
+					done <- err
+					return
+				}
+			}
+			done <- nil
+		}(s)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := held.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("lock sets deadlocked")
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Package locktest provides utilities for testing code using the lock package.
+package locktest
+
+import (
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/peertechde/lib/lock"
+)
+
+// Op identifies an operation of a lock.FileSystem.
+type Op string
+
+const (
+	OpStat       Op = "stat"
+	OpOpen       Op = "open"
+	OpFcntl      Op = "fcntl"
+	OpFlock      Op = "flock"
+	OpLockFileEx Op = "lockfileex"
+	OpClose      Op = "close"
+)
+
+// Fault describes the misbehavior injected into an operation.
+type Fault struct {
+	// Latency is added to every call of the operation.
+	Latency time.Duration
+
+	// Jitter adds a random duration in [0, Jitter) to Latency.
+	Jitter time.Duration
+
+	// Err is returned instead of performing the operation with probability
+	// Rate. A Rate of zero or below never injects Err, a Rate of one or above
+	// always does.
+	Err  error
+	Rate float64
+}
+
+// FaultFS is a lock.FileSystem which injects latency and errors into the
+// operations of another FileSystem. The injected faults are derived from a
+// seeded source, so a test using the same seed and the same sequence of
+// operations observes the same faults.
+type FaultFS struct {
+	fs lock.FileSystem
+
+	mu     sync.Mutex
+	rand   *rand.Rand
+	faults map[Op]Fault
+	calls  map[Op]int
+}
+
+// NewFaultFS returns a FaultFS wrapping fs. A nil fs wraps lock.OSFileSystem.
+func NewFaultFS(fs lock.FileSystem, seed int64) *FaultFS {
+	if fs == nil {
+		fs = lock.OSFileSystem
+	}
+	return &FaultFS{
+		fs:     fs,
+		rand:   rand.New(rand.NewSource(seed)),
+		faults: make(map[Op]Fault),
+		calls:  make(map[Op]int),
+	}
+}
+
+// Inject sets the fault of op, replacing any previous fault.
+func (f *FaultFS) Inject(op Op, fault Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.faults[op] = fault
+}
+
+// Reset removes all faults.
+func (f *FaultFS) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.faults = make(map[Op]Fault)
+}
+
+// Calls returns the number of calls of op.
+func (f *FaultFS) Calls(op Op) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls[op]
+}
+
+func (f *FaultFS) Stat(name string) (os.FileInfo, error) {
+	if err := f.inject(OpStat); err != nil {
+		return nil, err
+	}
+	return f.fs.Stat(name)
+}
+
+func (f *FaultFS) OpenFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	if err := f.inject(OpOpen); err != nil {
+		return nil, err
+	}
+	return f.fs.OpenFile(name, flag, perm)
+}
+
+// Close closes file. The file is closed even if an error is injected, so that
+// a failing close doesn't leak locks into subsequent tests.
+func (f *FaultFS) Close(file *os.File) error {
+	injected := f.inject(OpClose)
+	err := f.fs.Close(file)
+	if injected != nil {
+		return injected
+	}
+	return err
+}
+
+// inject sleeps for the latency of op and returns the error to inject, if any.
+func (f *FaultFS) inject(op Op) error {
+	f.mu.Lock()
+	f.calls[op]++
+	fault := f.faults[op]
+	latency := fault.Latency
+	if fault.Jitter > 0 {
+		latency += time.Duration(f.rand.Int63n(int64(fault.Jitter)))
+	}
+	var err error
+	if fault.Err != nil && fault.Rate > 0 && (fault.Rate >= 1 || f.rand.Float64() < fault.Rate) {
+		err = fault.Err
+	}
+	f.mu.Unlock()
+
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+	return err
+}
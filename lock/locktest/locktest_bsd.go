@@ -0,0 +1,15 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+// +build darwin dragonfly freebsd netbsd openbsd
+
+package locktest
+
+import (
+	"os"
+)
+
+func (f *FaultFS) Flock(file *os.File, how int) error {
+	if err := f.inject(OpFlock); err != nil {
+		return err
+	}
+	return f.fs.Flock(file, how)
+}
@@ -0,0 +1,14 @@
+package locktest
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func (f *FaultFS) Fcntl(file *os.File, cmd int, lk *unix.Flock_t) error {
+	if err := f.inject(OpFcntl); err != nil {
+		return err
+	}
+	return f.fs.Fcntl(file, cmd, lk)
+}
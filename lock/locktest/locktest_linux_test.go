@@ -0,0 +1,67 @@
+package locktest
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/peertechde/lib/lock"
+)
+
+func TestFaultFS(t *testing.T) {
+	file, err := ioutil.TempFile("", "locktest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	fs := NewFaultFS(nil, 1)
+	l := lock.New(file.Name())
+	l.Configure(lock.WithFileSystem(fs))
+
+	// latency
+	fs.Inject(OpOpen, Fault{Latency: 50 * time.Millisecond})
+	start := time.Now()
+	if err := l.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < 50*time.Millisecond {
+		t.Fatalf("expected acquisition to take at least %s, took %s", 50*time.Millisecond, waited)
+	}
+	if err := l.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// errors
+	fs.Reset()
+	fs.Inject(OpFcntl, Fault{Err: unix.ENOLCK, Rate: 1})
+	if err := l.TryLock(); !errors.Is(err, unix.ENOLCK) {
+		t.Fatalf("expected %v, got %v", unix.ENOLCK, err)
+	}
+	if fs.Calls(OpFcntl) != 2 || fs.Calls(OpClose) != 2 {
+		t.Fatalf("unexpected calls: fcntl %d, close %d", fs.Calls(OpFcntl), fs.Calls(OpClose))
+	}
+
+	// with a rate below one, the same seed injects the same faults
+	pattern := func() []bool {
+		fs := NewFaultFS(nil, 42)
+		fs.Inject(OpStat, Fault{Err: unix.EIO, Rate: 0.5})
+		var failed []bool
+		for i := 0; i < 16; i++ {
+			_, err := fs.Stat(file.Name())
+			failed = append(failed, err != nil)
+		}
+		return failed
+	}
+	first, second := pattern(), pattern()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("expected deterministic faults, got %v and %v", first, second)
+		}
+	}
+}
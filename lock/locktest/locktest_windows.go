@@ -0,0 +1,21 @@
+package locktest
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+func (f *FaultFS) LockFileEx(file *os.File, flags, bytesLow, bytesHigh uint32, ol *windows.Overlapped) error {
+	if err := f.inject(OpLockFileEx); err != nil {
+		return err
+	}
+	return f.fs.LockFileEx(file, flags, bytesLow, bytesHigh, ol)
+}
+
+func (f *FaultFS) UnlockFileEx(file *os.File, bytesLow, bytesHigh uint32, ol *windows.Overlapped) error {
+	if err := f.inject(OpLockFileEx); err != nil {
+		return err
+	}
+	return f.fs.UnlockFileEx(file, bytesLow, bytesHigh, ol)
+}
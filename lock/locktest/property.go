@@ -0,0 +1,189 @@
+package locktest
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+	"time"
+
+	"github.com/peertechde/lib/lock"
+)
+
+// PropertyConfig configures CheckProperties.
+type PropertyConfig struct {
+	// Owners is the number of competing owners, defaults to 3.
+	Owners int
+
+	// Programs is the number of generated operation sequences, defaults to 50.
+	Programs int
+
+	// Steps is the maximum length of an operation sequence, defaults to 20.
+	Steps int
+
+	// Seed seeds the generation of the operation sequences.
+	Seed int64
+
+	// Blocked is how long a contended Lock has to stay blocked, defaults to
+	// 20ms.
+	Blocked time.Duration
+
+	// Timeout bounds the time a Lock may take to return after the lock was
+	// released, defaults to 5s.
+	Timeout time.Duration
+}
+
+// CheckProperties runs random sequences of operations from multiple owners
+// against the owners returned by newOwner and compares the outcome with a
+// reference model of an exclusive lock. Every call of newOwner must return a
+// fresh owner of the same lock. It checks mutual exclusion, that is TryLock
+// fails while any owner holds the lock, and liveness, that is a blocked Lock
+// returns once the holder releases the lock.
+func CheckProperties(t *testing.T, config PropertyConfig, newOwner func() lock.Interface) {
+	t.Helper()
+
+	if config.Owners == 0 {
+		config.Owners = 3
+	}
+	if config.Programs == 0 {
+		config.Programs = 50
+	}
+	if config.Steps == 0 {
+		config.Steps = 20
+	}
+	if config.Blocked == 0 {
+		config.Blocked = 20 * time.Millisecond
+	}
+	if config.Timeout == 0 {
+		config.Timeout = 5 * time.Second
+	}
+
+	property := func(p program) bool {
+		owners := make([]lock.Interface, config.Owners)
+		for i := range owners {
+			owners[i] = newOwner()
+		}
+		if err := p.run(owners, config); err != nil {
+			t.Logf("program %v: %v", p, err)
+			return false
+		}
+		return true
+	}
+	err := quick.Check(property, &quick.Config{
+		MaxCount: config.Programs,
+		Rand:     rand.New(rand.NewSource(config.Seed)),
+		Values: func(values []reflect.Value, r *rand.Rand) {
+			values[0] = reflect.ValueOf(generate(r, config))
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+type op int
+
+const (
+	opTryLock op = iota
+	opLock
+	opUnlock
+)
+
+func (o op) String() string {
+	return [...]string{"TryLock", "Lock", "Unlock"}[o]
+}
+
+type step struct {
+	owner int
+	op    op
+}
+
+func (s step) String() string {
+	return fmt.Sprintf("%d.%s", s.owner, s.op)
+}
+
+type program []step
+
+func generate(r *rand.Rand, config PropertyConfig) program {
+	p := make(program, r.Intn(config.Steps)+1)
+	for i := range p {
+		p[i] = step{owner: r.Intn(config.Owners), op: op(r.Intn(3))}
+	}
+	return p
+}
+
+// run executes p against owners and the model. Steps which are undefined in
+// the current state of the model, like unlocking a lock that isn't held, are
+// skipped.
+func (p program) run(owners []lock.Interface, config PropertyConfig) error {
+	holder := -1
+	defer func() {
+		if holder >= 0 {
+			owners[holder].Unlock()
+		}
+	}()
+
+	for i, s := range p {
+		owner := owners[s.owner]
+		switch s.op {
+		case opTryLock:
+			err := owner.TryLock()
+			switch {
+			case holder < 0 && err != nil:
+				return fmt.Errorf("step %d: TryLock of free lock failed: %v", i, err)
+			case holder >= 0 && err == nil:
+				return fmt.Errorf("step %d: TryLock succeeded while %d holds the lock", i, holder)
+			case holder >= 0 && !errors.Is(err, lock.ErrLockLocked):
+				return fmt.Errorf("step %d: expected %v, got %v", i, lock.ErrLockLocked, err)
+			case holder < 0:
+				holder = s.owner
+			}
+		case opLock:
+			if holder == s.owner {
+				continue
+			}
+			if holder < 0 {
+				if err := owner.Lock(); err != nil {
+					return fmt.Errorf("step %d: Lock of free lock failed: %v", i, err)
+				}
+				holder = s.owner
+				continue
+			}
+
+			// the contended Lock must block until the holder releases
+			locked := make(chan error, 1)
+			go func() {
+				locked <- owner.Lock()
+			}()
+			select {
+			case err := <-locked:
+				return fmt.Errorf("step %d: Lock returned while %d holds the lock: %v", i, holder, err)
+			case <-time.After(config.Blocked):
+			}
+			if err := owners[holder].Unlock(); err != nil {
+				return fmt.Errorf("step %d: Unlock of %d failed: %v", i, holder, err)
+			}
+			holder = -1
+			select {
+			case err := <-locked:
+				if err != nil {
+					return fmt.Errorf("step %d: Lock failed after release: %v", i, err)
+				}
+				holder = s.owner
+			case <-time.After(config.Timeout):
+				return fmt.Errorf("step %d: Lock didn't return within %s after release", i, config.Timeout)
+			}
+		case opUnlock:
+			if holder != s.owner {
+				continue
+			}
+			if err := owner.Unlock(); err != nil {
+				return fmt.Errorf("step %d: Unlock failed: %v", i, err)
+			}
+			holder = -1
+		}
+	}
+	return nil
+}
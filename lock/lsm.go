@@ -0,0 +1,71 @@
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// PermissionError is returned if the lock file couldn't be opened or locked
+// due to missing permissions. The Hint suggests a fix of the file permissions;
+// on Linux, if a security module likely denied the access, it's named together
+// with a suggested fix instead.
+type PermissionError struct {
+	Op   string
+	Path string
+	Err  error
+
+	// Module is the security module likely denying the access, "selinux" or
+	// "apparmor", or empty if only the file permissions are in the way.
+	Module string
+	// Context is the security context of the process and, for SELinux, of
+	// the file.
+	Context string
+	// Hint suggests how to resolve the denial.
+	Hint string
+}
+
+func (e *PermissionError) Error() string {
+	msg := fmt.Sprintf("%s %s: %v", e.Op, e.Path, e.Err)
+	if e.Module != "" {
+		msg += fmt.Sprintf(" (likely denied by %s, %s)", e.Module, e.Context)
+	}
+	if e.Hint != "" {
+		msg += ": " + e.Hint
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error.
+func (e *PermissionError) Unwrap() error {
+	return e.Err
+}
+
+// diagnose returns a *PermissionError explaining err if op failed on the file
+// at path due to missing permissions. Other well known failures are annotated
+// with a *HintError, the remaining errors are returned as is.
+func diagnose(op, path string, err error) error {
+	if errors.Is(err, syscall.ENOLCK) {
+		return &HintError{Err: err, Hint: "the file system doesn't support locks, e.g. NFS without " +
+			"a lock manager, place the lock file on a local file system or use StrategyLinkLock"}
+	}
+	if hint := platformHint(op, err); hint != "" {
+		return &HintError{Err: err, Hint: hint}
+	}
+	if !errors.Is(err, os.ErrPermission) {
+		return err
+	}
+	e := &PermissionError{Op: op, Path: path, Err: err}
+
+	// the open mode is checked against the file permissions first, security
+	// modules only get involved if they allow the access
+	if op == "open" {
+		if hint := checkMode(path, os.Geteuid()); hint != "" {
+			e.Hint = hint
+			return e
+		}
+	}
+	diagnoseModule(e)
+	return e
+}
@@ -0,0 +1,134 @@
+package lock
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// lsmFiles locates the state of the security modules, tests replace them with
+// fixtures.
+var lsmFiles = struct {
+	selinuxEnforce string
+	procAttr       string
+	apparmorAttr   string
+	auditLog       string
+}{
+	selinuxEnforce: "/sys/fs/selinux/enforce",
+	procAttr:       "/proc/self/attr/current",
+	apparmorAttr:   "/proc/self/attr/apparmor/current",
+	auditLog:       "/var/log/audit/audit.log",
+}
+
+// auditTail bounds the amount of the audit log searched for denials.
+const auditTail = 64 << 10
+
+// platformHint returns a hint for failures of op specific to Linux.
+func platformHint(op string, err error) string {
+	if op == "lock" && errors.Is(err, unix.EINVAL) {
+		return "the kernel doesn't support open file description locks, Linux 3.15 or newer is required"
+	}
+	return ""
+}
+
+// diagnoseModule names the security module likely denying the access of e
+// and suggests a fix.
+func diagnoseModule(e *PermissionError) {
+	// the security modules can't be inspected in sandboxes
+	if Restricted() {
+		return
+	}
+	path := e.Path
+	attr := readAttr(lsmFiles.procAttr)
+	switch {
+	case readAttr(lsmFiles.selinuxEnforce) == "1":
+		e.Module = "selinux"
+		e.Context = "process " + attr
+		label := fileLabel(path)
+		if label != "" {
+			e.Context += ", file " + label
+		}
+		e.Hint = fmt.Sprintf("check the denial with ausearch -m avc -ts recent | audit2why, "+
+			"relabel the file with restorecon -v %s or allow the access in the policy", path)
+		if strings.Contains(label, ":unlabeled_t:") || strings.Contains(label, ":default_t:") {
+			e.Hint = fmt.Sprintf("the file has the generic type of %s, relabel it with "+
+				"semanage fcontext and restorecon -v %s", label, path)
+		}
+	default:
+		profile := readAttr(lsmFiles.apparmorAttr)
+		if profile == "" {
+			profile = attr
+		}
+		if profile == "" || profile == "unconfined" || strings.HasPrefix(profile, "kernel") {
+			return
+		}
+		e.Module = "apparmor"
+		e.Context = "profile " + profile
+		e.Hint = fmt.Sprintf("allow the access with the rule \"%s rwk,\" in the profile, "+
+			"locking requires the k permission", path)
+	}
+	if record := auditDenial(path); record != "" {
+		e.Hint += "; audit: " + record
+	}
+}
+
+// readAttr returns the trimmed content of the file at name or an empty string
+// if it can't be read.
+func readAttr(name string) string {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		restrict("read "+name, err)
+		return ""
+	}
+	return strings.TrimSpace(string(bytes.TrimRight(data, "\x00")))
+}
+
+// fileLabel returns the SELinux context of the file at path.
+func fileLabel(path string) string {
+	buf := make([]byte, 256)
+	n, err := unix.Getxattr(path, "security.selinux", buf)
+	if err != nil {
+		restrict("getxattr", err)
+		return ""
+	}
+	return string(bytes.TrimRight(buf[:n], "\x00"))
+}
+
+// auditDenial returns the last denial of the file at path recorded in the
+// recent audit log, if it's readable.
+func auditDenial(path string) string {
+	f, err := os.Open(lsmFiles.auditLog)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	if fi, err := f.Stat(); err == nil && fi.Size() > auditTail {
+		if _, err := f.Seek(-auditTail, io.SeekEnd); err != nil {
+			return ""
+		}
+	}
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return ""
+	}
+	name := fmt.Sprintf("name=\"%s\"", filepath.Base(path))
+	lines := strings.Split(string(data), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := lines[i]
+		if strings.Contains(strings.ToLower(line), "denied") && (strings.Contains(line, name) || strings.Contains(line, path)) {
+			if len(line) > 200 {
+				line = line[:200] + "..."
+			}
+			return line
+		}
+	}
+	return ""
+}
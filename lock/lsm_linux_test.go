@@ -0,0 +1,131 @@
+package lock
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestDiagnose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	saved := lsmFiles
+	defer func() { lsmFiles = saved }()
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	lockPath := write("app.lock", "")
+	lsmFiles.selinuxEnforce = filepath.Join(dir, "missing")
+	lsmFiles.apparmorAttr = filepath.Join(dir, "missing")
+	lsmFiles.auditLog = filepath.Join(dir, "missing")
+
+	// other errors pass through
+	if err := diagnose("lock", lockPath, unix.EBADF); err != unix.EBADF {
+		t.Fatalf("expected %v, got %v", unix.EBADF, err)
+	}
+
+	// unconfined
+	lsmFiles.procAttr = write("unconfined", "unconfined\n")
+	var perr *PermissionError
+	err = lockFailed(lockPath, unix.EACCES)
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected *PermissionError, got %T", err)
+	}
+	if perr.Module != "" || !errors.Is(err, unix.EACCES) {
+		t.Fatalf("unexpected error %+v", perr)
+	}
+
+	// apparmor
+	lsmFiles.apparmorAttr = write("apparmor", "app (enforce)\n")
+	lsmFiles.auditLog = write("audit.log", `type=AVC msg=audit(1): apparmor="DENIED" operation="file_lock" name="/other"
+type=AVC msg=audit(2): apparmor="DENIED" operation="file_lock" name="`+lockPath+`" requested_mask="k"
+`)
+	err = diagnose("lock", lockPath, unix.EACCES)
+	if !errors.As(err, &perr) || perr.Module != "apparmor" || perr.Context != "profile app (enforce)" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !strings.Contains(perr.Hint, lockPath+" rwk,") || !strings.Contains(perr.Hint, "audit(2)") {
+		t.Fatalf("unexpected hint %q", perr.Hint)
+	}
+
+	// selinux
+	lsmFiles.selinuxEnforce = write("enforce", "1")
+	lsmFiles.procAttr = write("selinux", "system_u:system_r:httpd_t:s0\x00")
+	lsmFiles.auditLog = filepath.Join(dir, "missing")
+	err = diagnose("open", lockPath, &os.PathError{Op: "open", Path: lockPath, Err: unix.EACCES})
+	if !errors.As(err, &perr) || perr.Module != "selinux" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !strings.HasPrefix(perr.Context, "process system_u:system_r:httpd_t:s0") ||
+		!strings.Contains(perr.Hint, "restorecon -v "+lockPath) {
+		t.Fatalf("unexpected error %+v", perr)
+	}
+	if !strings.Contains(err.Error(), "likely denied by selinux") {
+		t.Fatalf("unexpected message %q", err.Error())
+	}
+
+	// restricted
+	SetRestricted(true)
+	defer SetRestricted(false)
+	err = diagnose("lock", lockPath, unix.EACCES)
+	if !errors.As(err, &perr) || perr.Module != "" {
+		t.Fatalf("expected security modules to be skipped, got %v", err)
+	}
+}
+
+func TestDiagnoseHints(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var herr *HintError
+	err = lockFailed("/mnt/nfs/lock", unix.ENOLCK)
+	if !errors.As(err, &herr) || !strings.Contains(herr.Hint, "local file system") || !errors.Is(err, unix.ENOLCK) {
+		t.Fatalf("unexpected error %v", err)
+	}
+	err = diagnose("lock", "/var/lock/app", unix.EINVAL)
+	if !errors.As(err, &herr) || !strings.Contains(herr.Hint, "3.15") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := diagnose("open", "/var/lock/app", unix.EINVAL); err != unix.EINVAL {
+		t.Fatalf("expected %v, got %v", unix.EINVAL, err)
+	}
+
+	// lock file of another user
+	path := filepath.Join(dir, "app.lock")
+	if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	uid := os.Geteuid() + 1000
+	if hint := checkMode(path, uid); !strings.Contains(hint, "doesn't allow uid") {
+		t.Fatalf("unexpected hint %q", hint)
+	}
+	if err := os.Chmod(dir, 01777); err != nil {
+		t.Fatal(err)
+	}
+	if hint := checkMode(path, uid); !strings.Contains(hint, "chmod g+s") {
+		t.Fatalf("unexpected hint %q", hint)
+	}
+	if err := os.Chmod(path, 0666); err != nil {
+		t.Fatal(err)
+	}
+	if hint := checkMode(path, uid); hint != "" {
+		t.Fatalf("unexpected hint %q", hint)
+	}
+}
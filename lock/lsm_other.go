@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package lock
+
+// platformHint returns no hint, the failures specific to a platform are only
+// known for Linux.
+func platformHint(op string, err error) string {
+	return ""
+}
+
+// diagnoseModule leaves e as is, there are no security modules to diagnose
+// outside of Linux.
+func diagnoseModule(e *PermissionError) {}
@@ -0,0 +1,46 @@
+package lock
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestDiagnoseShared(t *testing.T) {
+	var herr *HintError
+	err := diagnose("lock", "/mnt/nfs/lock", syscall.ENOLCK)
+	if !errors.As(err, &herr) || !strings.Contains(herr.Hint, "StrategyLinkLock") || !errors.Is(err, syscall.ENOLCK) {
+		t.Fatalf("expected hint to use another backend, got %v", err)
+	}
+
+	var perr *PermissionError
+	err = diagnose("lock", "/var/lock/app", &os.PathError{Op: "lock", Path: "/var/lock/app", Err: syscall.EACCES})
+	if !errors.As(err, &perr) || !errors.Is(err, syscall.EACCES) {
+		t.Fatalf("expected *PermissionError, got %v", err)
+	}
+
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes don't control the access on windows")
+	}
+	dir, err := ioutil.TempDir("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.lock")
+	if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dir, 01777); err != nil {
+		t.Fatal(err)
+	}
+	// a lock file of another user in a shared directory
+	if hint := checkMode(path, os.Geteuid()+1000); !strings.Contains(hint, "chmod g+s") {
+		t.Fatalf("expected hint to share the group of the shared directory, got %q", hint)
+	}
+}
@@ -0,0 +1,59 @@
+//go:build !windows
+// +build !windows
+
+package lock
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// checkMode returns a hint if the permissions of the file at path don't allow
+// uid to open it for reading and writing.
+func checkMode(path string, uid int) string {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+	if uid == 0 {
+		return ""
+	}
+	perm := fi.Mode().Perm()
+	switch {
+	case int(st.Uid) == uid:
+		perm >>= 6
+	case inGroup(int(st.Gid)):
+		perm >>= 3
+	}
+	if perm&06 == 06 {
+		return ""
+	}
+	if dir, err := os.Stat(filepath.Dir(path)); err == nil && dir.Mode()&(os.ModeSticky|0022) != 0 {
+		// other users create lock files in shared directories
+		return fmt.Sprintf("the lock file in the shared directory %s is owned by %d:%d with mode %s, "+
+			"make it group writable with chmod g+rw and set the setgid bit of the directory with "+
+			"chmod g+s, so that the users of the lock share its group",
+			filepath.Dir(path), st.Uid, st.Gid, fi.Mode().Perm())
+	}
+	return fmt.Sprintf("the mode %s of the file owned by %d:%d doesn't allow uid %d to read and write it",
+		fi.Mode().Perm(), st.Uid, st.Gid, uid)
+}
+
+func inGroup(gid int) bool {
+	if os.Getegid() == gid {
+		return true
+	}
+	gids, _ := os.Getgroups()
+	for _, g := range gids {
+		if g == gid {
+			return true
+		}
+	}
+	return false
+}
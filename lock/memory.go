@@ -0,0 +1,149 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/peertechde/lib/clock"
+)
+
+// BackendMemory keeps locks in the memory of the process, see Memory. Open
+// returns the locks of a process wide Memory.
+const BackendMemory Backend = "memory"
+
+func init() {
+	RegisterBackend(BackendMemory, func(config Config) (Interface, error) {
+		return defaultMemory.Locker(config.Path, config.Timeout), nil
+	})
+}
+
+var defaultMemory = NewMemory()
+
+// Memory is a namespace of locks kept in the memory of the process, a fake of
+// file locks for tests. Its locks block, time out and fail like Lockers
+// without touching the file system, they don't exclude other processes though.
+// Tests running in parallel use a Memory each.
+type Memory struct {
+	mu    sync.Mutex
+	clock clock.Clock
+	locks map[string]chan struct{}
+}
+
+// NewMemory returns an empty Memory.
+func NewMemory() *Memory {
+	return &Memory{clock: defaultClock(), locks: make(map[string]chan struct{})}
+}
+
+// SetClock sets the clock timing the timeouts of the MemoryLockers returned
+// afterwards, e.g. a clock.Fake in tests. A nil Clock selects the default.
+func (m *Memory) SetClock(c clock.Clock) {
+	if c == nil {
+		c = defaultClock()
+	}
+	m.mu.Lock()
+	m.clock = c
+	m.mu.Unlock()
+}
+
+// Locker returns a new owner of the lock called name. A zero timeout makes
+// Lock block until the lock is acquired.
+func (m *Memory) Locker(name string, timeout time.Duration) *MemoryLocker {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	lock, ok := m.locks[name]
+	if !ok {
+		// the lock is held while its slot is filled
+		lock = make(chan struct{}, 1)
+		m.locks[name] = lock
+	}
+	return &MemoryLocker{name: name, timeout: timeout, clock: m.clock, lock: lock}
+}
+
+// MemoryLocker is an owner of a lock of a Memory. Like a Locker, it's safe for
+// concurrent use and held by a single goroutine at a time.
+type MemoryLocker struct {
+	name    string
+	timeout time.Duration
+	clock   clock.Clock
+	lock    chan struct{}
+
+	mu   sync.Mutex
+	held bool
+}
+
+var _ Interface = (*MemoryLocker)(nil)
+
+// Name returns the name of the lock.
+func (l *MemoryLocker) Name() string {
+	return l.name
+}
+
+// Lock blocks until the lock is acquired or the timeout expired.
+func (l *MemoryLocker) Lock() error {
+	return l.LockContext(context.Background())
+}
+
+// LockContext locks like Lock but gives up once ctx is done.
+func (l *MemoryLocker) LockContext(ctx context.Context) error {
+	expired, stop := expiry(l.clock, l.timeout)
+	defer stop()
+	select {
+	case l.lock <- struct{}{}:
+	case <-ctx.Done():
+		return cancelled(ctx.Err())
+	case <-expired:
+		return ErrLockTimeout
+	}
+	l.hold()
+	return nil
+}
+
+// TryLock acquires the lock without blocking. It returns ErrLockLocked if the
+// lock is held by another owner.
+func (l *MemoryLocker) TryLock() error {
+	select {
+	case l.lock <- struct{}{}:
+	default:
+		return ErrLockLocked
+	}
+	l.hold()
+	return nil
+}
+
+// TryLockContext locks like TryLock unless ctx is already done.
+func (l *MemoryLocker) TryLockContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return cancelled(err)
+	}
+	return l.TryLock()
+}
+
+// Unlock releases the lock. It returns ErrNotLocked if the MemoryLocker
+// doesn't hold the lock.
+func (l *MemoryLocker) Unlock() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if !l.held {
+		return ErrNotLocked
+	}
+	l.held = false
+	<-l.lock
+	return nil
+}
+
+// IsLocked reports whether the MemoryLocker holds the lock.
+func (l *MemoryLocker) IsLocked() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.held
+}
+
+func (l *MemoryLocker) hold() {
+	l.mu.Lock()
+	l.held = true
+	l.mu.Unlock()
+}
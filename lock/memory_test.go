@@ -0,0 +1,107 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/peertechde/lib/clock"
+)
+
+func TestMemory(t *testing.T) {
+	t.Parallel()
+
+	m := NewMemory()
+	l1, l2 := m.Locker("jobs", 0), m.Locker("jobs", 50*time.Millisecond)
+	if err := l1.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if !l1.IsLocked() || l2.IsLocked() {
+		t.Fatal("expected only l1 to be locked")
+	}
+	if err := l2.TryLock(); err != ErrLockLocked {
+		t.Fatalf("expected %v, got %v", ErrLockLocked, err)
+	}
+	if err := l2.Lock(); err != ErrLockTimeout {
+		t.Fatalf("expected %v, got %v", ErrLockTimeout, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l1.TryLockContext(ctx); !errors.Is(err, ErrLockCancelled) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v, got %v", ErrLockCancelled, err)
+	}
+	if err := l2.Unlock(); err != ErrNotLocked {
+		t.Fatalf("expected %v, got %v", ErrNotLocked, err)
+	}
+
+	// other names and other Memories don't conflict
+	if err := m.Locker("other", 0).TryLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewMemory().Locker("jobs", 0).TryLock(); err != nil {
+		t.Fatal(err)
+	}
+
+	locked := make(chan error, 1)
+	go func() {
+		locked <- m.Locker("jobs", 0).LockContext(context.Background())
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if err := l1.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-locked; err != nil {
+		t.Fatal(err)
+	}
+	if err := l1.Unlock(); err != ErrNotLocked {
+		t.Fatalf("expected %v, got %v", ErrNotLocked, err)
+	}
+}
+
+func TestMemoryClock(t *testing.T) {
+	t.Parallel()
+
+	fake := clock.NewFake(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	m := NewMemory()
+	m.SetClock(fake)
+	if err := m.Locker("jobs", 0).Lock(); err != nil {
+		t.Fatal(err)
+	}
+	locked := make(chan error, 1)
+	go func() {
+		locked <- m.Locker("jobs", time.Hour).Lock()
+	}()
+	for fake.Timers() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	fake.Advance(time.Hour)
+	select {
+	case err := <-locked:
+		if err != ErrLockTimeout {
+			t.Fatalf("expected %v, got %v", ErrLockTimeout, err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("lock didn't time out")
+	}
+}
+
+func TestMemoryBackend(t *testing.T) {
+	l1, err := Open(BackendMemory, Config{Path: "memory-backend-test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l2, err := Open(BackendMemory, Config{Path: "memory-backend-test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l1.TryLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l2.TryLock(); err != ErrLockLocked {
+		t.Fatalf("expected %v, got %v", ErrLockLocked, err)
+	}
+	if err := l1.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
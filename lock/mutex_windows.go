@@ -0,0 +1,203 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/windows"
+
+	"github.com/peertechde/lib/clock"
+)
+
+// BackendMutex uses named kernel mutexes. It's only available on Windows.
+const BackendMutex Backend = "mutex"
+
+// mutexPoll is the interval LockContext checks its context at.
+const mutexPoll = 50 * time.Millisecond
+
+// Scope is the namespace of a named kernel mutex.
+type Scope string
+
+const (
+	// ScopeLocal limits the mutex to the session of the process.
+	ScopeLocal Scope = "local"
+	// ScopeGlobal shares the mutex between all sessions, e.g. between services
+	// and interactive users.
+	ScopeGlobal Scope = "global"
+)
+
+func init() {
+	RegisterBackend(BackendMutex, func(config Config) (Interface, error) {
+		scope := ScopeLocal
+		if s, ok := config.Params["scope"]; ok {
+			scope = Scope(s)
+		}
+		return NewMutex(config.Path, scope, config.Timeout)
+	})
+}
+
+// Mutex is a lock backed by a named kernel mutex. Unlike a Locker it doesn't
+// need a file, processes agree on the name of the mutex instead.
+type Mutex struct {
+	name    string
+	timeout time.Duration
+	// clock times the deadline of LockContext
+	clock clock.Clock
+
+	mu   sync.Mutex
+	held chan chan error
+}
+
+var _ Interface = (*Mutex)(nil)
+
+// NewMutex returns a Mutex for the kernel mutex name in scope. A zero timeout
+// makes Lock block until the mutex is acquired.
+func NewMutex(name string, scope Scope, timeout time.Duration) (*Mutex, error) {
+	if name == "" || strings.Contains(name, `\`) {
+		return nil, fmt.Errorf("invalid mutex name %q", name)
+	}
+	var prefix string
+	switch scope {
+	case ScopeLocal:
+		prefix = `Local\`
+	case ScopeGlobal:
+		prefix = `Global\`
+	default:
+		return nil, fmt.Errorf("unknown mutex scope %q", scope)
+	}
+	return &Mutex{name: prefix + name, timeout: timeout, clock: defaultClock()}, nil
+}
+
+// Name returns the name of the kernel mutex including the namespace.
+func (m *Mutex) Name() string {
+	return m.name
+}
+
+// Lock blocks until the mutex is acquired or the timeout expired.
+func (m *Mutex) Lock() error {
+	wait := uint32(windows.INFINITE)
+	if m.timeout > 0 {
+		wait = uint32(m.timeout / time.Millisecond)
+	}
+	err := m.acquire(wait)
+	if err == ErrLockLocked {
+		return ErrLockTimeout
+	}
+	return err
+}
+
+// LockContext blocks like Lock until the mutex is acquired, the timeout
+// expired or ctx is done. The wait for the kernel mutex is interrupted every
+// mutexPoll to check ctx.
+func (m *Mutex) LockContext(ctx context.Context) error {
+	if ctx.Done() == nil {
+		return m.Lock()
+	}
+	var deadline time.Time
+	if m.timeout > 0 {
+		deadline = m.clock.Now().Add(m.timeout)
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return cancelled(err)
+		}
+		wait := mutexPoll
+		if !deadline.IsZero() {
+			remaining := deadline.Sub(m.clock.Now())
+			if remaining <= 0 {
+				return ErrLockTimeout
+			}
+			if remaining < wait {
+				wait = remaining
+			}
+		}
+		if err := m.acquire(uint32(wait / time.Millisecond)); err != ErrLockLocked {
+			return err
+		}
+	}
+}
+
+// TryLock acquires the mutex if it isn't held elsewhere, otherwise it returns
+// ErrLockLocked.
+func (m *Mutex) TryLock() error {
+	return m.acquire(0)
+}
+
+// TryLockContext acquires the mutex like TryLock unless ctx is already done.
+func (m *Mutex) TryLockContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return cancelled(err)
+	}
+	return m.TryLock()
+}
+
+// Unlock releases the mutex.
+func (m *Mutex) Unlock() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.held == nil {
+		return ErrNotLocked
+	}
+	done := make(chan error)
+	m.held <- done
+	m.held = nil
+	if err := <-done; err != nil {
+		return fmt.Errorf("release failed: %w", err)
+	}
+	return nil
+}
+
+// acquire waits up to wait milliseconds for the mutex. Kernel mutexes are owned
+// by a thread, so the mutex is acquired and released by a goroutine locked to
+// its thread for as long as the mutex is held.
+func (m *Mutex) acquire(wait uint32) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.held != nil {
+		return ErrAlreadyLocked
+	}
+	name, err := windows.UTF16PtrFromString(m.name)
+	if err != nil {
+		return fmt.Errorf("invalid mutex name: %w", err)
+	}
+	acquired := make(chan error)
+	release := make(chan chan error)
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		h, err := windows.CreateMutex(nil, false, name)
+		if h == 0 {
+			acquired <- fmt.Errorf("create mutex failed: %w", err)
+			return
+		}
+		defer windows.CloseHandle(h)
+
+		event, err := windows.WaitForSingleObject(h, wait)
+		switch {
+		case err != nil:
+			acquired <- fmt.Errorf("wait failed: %w", err)
+			return
+		case event == uint32(windows.WAIT_TIMEOUT):
+			acquired <- ErrLockLocked
+			return
+		}
+		// an abandoned mutex was held by a thread which exited, the ownership
+		// passes to this thread like for a released mutex
+		acquired <- nil
+
+		done := <-release
+		done <- windows.ReleaseMutex(h)
+	}()
+	if err := <-acquired; err != nil {
+		return err
+	}
+	m.held = release
+	return nil
+}
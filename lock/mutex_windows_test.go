@@ -0,0 +1,67 @@
+package lock
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMutex(t *testing.T) {
+	name := fmt.Sprintf("lock-test-%d", os.Getpid())
+
+	m1, err := NewMutex(name, ScopeLocal, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2, err := NewMutex(name, ScopeLocal, 100*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m1.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m2.TryLock(); err != ErrLockLocked {
+		t.Fatalf("expected %v, got %v", ErrLockLocked, err)
+	}
+	if err := m2.Lock(); err != ErrLockTimeout {
+		t.Fatalf("expected %v, got %v", ErrLockTimeout, err)
+	}
+	if err := m1.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m2.TryLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m2.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := m2.Unlock(); err == nil {
+		t.Fatal("expected unlock of released mutex to fail")
+	}
+}
+
+func TestMutexConfig(t *testing.T) {
+	m, err := NewMutex("name", ScopeGlobal, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name() != `Global\name` {
+		t.Fatalf("unexpected name %q", m.Name())
+	}
+	if _, err := NewMutex(`a\b`, ScopeLocal, 0); err == nil {
+		t.Fatal("expected name with backslash to fail")
+	}
+	if _, err := NewMutex("name", "session", 0); err == nil {
+		t.Fatal("expected unknown scope to fail")
+	}
+
+	l, err := Open(BackendMutex, Config{Path: "name", Params: map[string]string{"scope": "global"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := l.(*Mutex).Name(); got != `Global\name` {
+		t.Fatalf("unexpected name %q", got)
+	}
+}
@@ -0,0 +1,40 @@
+package lock
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Named returns a Locker of the lock called name, a per-name mutex shared by
+// the processes of the host. The lock file name.lock is kept in the first
+// writable directory of $XDG_RUNTIME_DIR, the lock directory of the system,
+// /run/lock, and a private directory of the user within os.TempDir. The file
+// is created on acquisition, readable and writable by the user only; the
+// runtime directories are cleared by the system on logout or reboot. opts
+// configure the Locker further.
+//
+// Processes of different users share named locks only in the lock directory
+// of the system.
+func Named(name string, opts ...Option) (*Locker, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return nil, fmt.Errorf("invalid lock name %q: %w", name, os.ErrInvalid)
+	}
+	dir, err := runtimeDir()
+	if err != nil {
+		return nil, err
+	}
+	opts = append([]Option{WithCreate(0600)}, opts...)
+	return New(filepath.Join(dir, name+".lock"), opts...), nil
+}
+
+// runtimeDir returns the directory of the files of named locks.
+func runtimeDir() (string, error) {
+	for _, dir := range []string{os.Getenv("XDG_RUNTIME_DIR"), systemLockDir} {
+		if dir != "" && writableDir(dir) {
+			return dir, nil
+		}
+	}
+	return privateTempDir()
+}
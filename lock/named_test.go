@@ -0,0 +1,72 @@
+package lock
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestNamed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer setenv(t, map[string]string{"XDG_RUNTIME_DIR": dir})()
+
+	for _, name := range []string{"", ".", "..", "a/b", `a\b`} {
+		if _, err := Named(name); !errors.Is(err, os.ErrInvalid) {
+			t.Fatalf("expected name %q to be invalid, got %v", name, err)
+		}
+	}
+
+	l1, err := Named("my-daemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "my-daemon.lock")
+	if l1.Path() != path {
+		t.Fatalf("expected path %s, got %s", path, l1.Path())
+	}
+	if err := l1.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && fi.Mode().Perm() != 0600 {
+		t.Fatalf("expected mode %v, got %v", os.FileMode(0600), fi.Mode().Perm())
+	}
+	l2, err := Named("my-daemon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l2.TryLock(); err != ErrLockLocked {
+		t.Fatalf("expected %v, got %v", ErrLockLocked, err)
+	}
+	if err := l1.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPrivateTempDir(t *testing.T) {
+	dir, err := privateTempDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !writableDir(dir) {
+		t.Fatalf("expected %s to be writable", dir)
+	}
+	// the directory is reused
+	again, err := privateTempDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != dir {
+		t.Fatalf("expected %s, got %s", dir, again)
+	}
+}
@@ -0,0 +1,98 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/peertechde/lib/clock"
+)
+
+// TryLockOrNotify acquires the lock like TryLock and reports whether it did.
+// If the lock is held elsewhere, the returned channel is closed once the lock
+// is likely free, so that event loops can select on it and retry instead of
+// blocking a goroutine in Lock. The release of the lock is noticed through
+// inotify where available, otherwise the lock is polled at the retry
+// interval. Another waiter may acquire the lock first, TryLock can still fail
+// after the channel was closed.
+//
+// The lock is watched until ctx is done, which closes the channel as well and
+// releases the descriptors of the watch; callers which lose interest cancel
+// ctx.
+func (l *Locker) TryLockOrNotify(ctx context.Context) (bool, <-chan struct{}, error) {
+	err := l.TryLockContext(ctx)
+	if err == nil {
+		return true, nil, nil
+	}
+	if !errors.Is(err, ErrLockLocked) {
+		return false, nil, err
+	}
+	if l.linked() {
+		return false, nil, errLinkLock
+	}
+	h, err := l.open()
+	if err != nil {
+		return false, nil, err
+	}
+	free := make(chan struct{})
+	go l.notifyFree(ctx, h.path, h.fs, h.file, free)
+	return false, free, nil
+}
+
+// notifyFree closes free once the lock of file isn't held anymore, can't be
+// queried or ctx is done.
+func (l *Locker) notifyFree(ctx context.Context, path string, fs FileSystem, file *os.File, free chan struct{}) {
+	defer close(free)
+	defer fs.Close(file)
+
+	// the watch is set up before the first check, a release in between isn't
+	// missed
+	watch, err := newCloseWatch(path)
+	if err == nil {
+		stop, stopped := make(chan struct{}), make(chan struct{})
+		go func() {
+			defer close(stopped)
+			select {
+			case <-ctx.Done():
+				watch.interrupt()
+			case <-stop:
+			}
+		}()
+		defer func() {
+			close(stop)
+			<-stopped
+			watch.close()
+		}()
+	}
+	for ctx.Err() == nil {
+		l.mu.Lock()
+		interval, window, clk := l.retryInterval, l.jitter, l.clock
+		l.mu.Unlock()
+
+		if locked, err := isLocked(fs, file); err != nil || !locked {
+			// the watchers of a lock are notified of a release at once,
+			// spread the notifications
+			if d := jitter(window); d > 0 {
+				sleep(ctx, clk, d)
+			}
+			return
+		}
+		if watch != nil {
+			watch.wait(interval)
+			continue
+		}
+		sleep(ctx, clk, interval)
+	}
+}
+
+// sleep waits for d on clk or until ctx is done.
+func sleep(ctx context.Context, clk clock.Clock, d time.Duration) {
+	timer := clk.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,69 @@
+package lock
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// closeWatch reports when a file is closed by any process, which releases the
+// locks held through it.
+type closeWatch struct {
+	fd int
+	// wake interrupts wait, see interrupt
+	wake [2]int
+}
+
+func newCloseWatch(path string) (*closeWatch, error) {
+	if Restricted() {
+		return nil, unix.EPERM
+	}
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		restrict("inotify", err)
+		return nil, err
+	}
+	if _, err := unix.InotifyAddWatch(fd, path, unix.IN_CLOSE); err != nil {
+		unix.Close(fd)
+		restrict("inotify", err)
+		return nil, err
+	}
+	w := &closeWatch{fd: fd}
+	if err := unix.Pipe2(w.wake[:], unix.O_CLOEXEC|unix.O_NONBLOCK); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	return w, nil
+}
+
+// wait waits up to d for the file to be closed or wait to be interrupted.
+func (w *closeWatch) wait(d time.Duration) {
+	fds := []unix.PollFd{
+		{Fd: int32(w.fd), Events: unix.POLLIN},
+		{Fd: int32(w.wake[0]), Events: unix.POLLIN},
+	}
+	n, err := unix.Poll(fds, int(d/time.Millisecond))
+	if err != nil || n == 0 {
+		return
+	}
+	// drain the events, they carry nothing beyond the close
+	buf := make([]byte, 4096)
+	for _, fd := range []int{w.fd, w.wake[0]} {
+		for {
+			if _, err := unix.Read(fd, buf); err != nil {
+				break
+			}
+		}
+	}
+}
+
+// interrupt makes the current or next call of wait return.
+func (w *closeWatch) interrupt() {
+	unix.Write(w.wake[1], []byte{0})
+}
+
+func (w *closeWatch) close() {
+	unix.Close(w.fd)
+	unix.Close(w.wake[0])
+	unix.Close(w.wake[1])
+}
@@ -0,0 +1,21 @@
+//go:build !linux
+// +build !linux
+
+package lock
+
+import (
+	"time"
+)
+
+// closeWatch is only available on Linux, the lock is polled instead.
+type closeWatch struct{}
+
+func newCloseWatch(path string) (*closeWatch, error) {
+	return nil, errUnsupported
+}
+
+func (w *closeWatch) wait(d time.Duration) {}
+
+func (w *closeWatch) interrupt() {}
+
+func (w *closeWatch) close() {}
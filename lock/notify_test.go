@@ -0,0 +1,111 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTryLockOrNotify(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	for _, restricted := range []bool{false, true} {
+		SetRestricted(restricted)
+
+		holder := New(file.Name())
+		held, free, err := holder.TryLockOrNotify(context.Background())
+		if err != nil || !held || free != nil {
+			t.Fatalf("expected lock to be acquired, got %t, %v", held, err)
+		}
+
+		// the release is noticed through inotify long before the retry
+		// interval, or by polling in restricted mode
+		interval := time.Hour
+		if restricted {
+			interval = 10 * time.Millisecond
+		}
+		waiter := New(file.Name(), WithRetryInterval(interval))
+		held, free, err = waiter.TryLockOrNotify(context.Background())
+		if err != nil || held || free == nil {
+			t.Fatalf("expected notification, got %t, %v", held, err)
+		}
+		select {
+		case <-free:
+			t.Fatal("notified while the lock is held")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		if err := holder.Unlock(); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-free:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("release wasn't notified (restricted %t)", restricted)
+		}
+		if err := waiter.TryLock(); err != nil {
+			t.Fatal(err)
+		}
+		if err := waiter.Unlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	SetRestricted(false)
+
+	if _, _, err := New(file.Name() + "-missing").TryLockOrNotify(context.Background()); err == nil {
+		t.Fatal("expected missing file to fail")
+	}
+}
+
+func TestTryLockOrNotifyCancel(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	holder := New(file.Name())
+	if err := holder.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	defer holder.Unlock()
+
+	// the descriptors are counted where the process lists them
+	fds := func() int {
+		entries, err := ioutil.ReadDir("/proc/self/fd")
+		if err != nil {
+			return -1
+		}
+		return len(entries)
+	}
+	before := fds()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	held, free, err := New(file.Name(), WithRetryInterval(time.Hour)).TryLockOrNotify(ctx)
+	if err != nil || held || free == nil {
+		t.Fatalf("expected notification, got %t, %v", held, err)
+	}
+	cancel()
+	select {
+	case <-free:
+	case <-time.After(2 * time.Second):
+		t.Fatal("cancellation didn't stop the watch")
+	}
+	// the descriptors are closed before the channel
+	if after := fds(); after != before {
+		t.Fatalf("expected %d open descriptors, got %d", before, after)
+	}
+
+	if _, _, err := New(file.Name()).TryLockOrNotify(ctx); !errors.Is(err, ErrLockCancelled) {
+		t.Fatalf("expected %v, got %v", ErrLockCancelled, err)
+	}
+}
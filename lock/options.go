@@ -0,0 +1,273 @@
+package lock
+
+import (
+	"os"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/peertechde/lib/clock"
+)
+
+// Option configures a Locker.
+type Option func(*Locker)
+
+// WithRetryInterval sets the interval between acquisition attempts of Lock in
+// polling mode, see WithPolling. A zero interval selects the default. It
+// replaces the Backoff set by WithBackoff.
+func WithRetryInterval(interval time.Duration) Option {
+	return func(l *Locker) {
+		if interval == time.Duration(0) {
+			interval = defaultRetryInterval
+		}
+		l.retryInterval = interval
+		l.backoff = nil
+	}
+}
+
+// WithBackoff schedules the retries of Lock in polling mode with b instead of
+// the fixed retry interval, e.g. ExponentialBackoff. A nil Backoff selects the
+// retry interval again.
+func WithBackoff(b Backoff) Option {
+	return func(l *Locker) {
+		l.backoff = b
+	}
+}
+
+// WithCreate creates the lock file with perm, before umask, if it doesn't
+// exist on acquisition. The file is created atomically by opening it with
+// O_CREATE.
+func WithCreate(perm os.FileMode) Option {
+	return func(l *Locker) {
+		l.create = true
+		l.perm = perm
+	}
+}
+
+// WithOpenFlags adds flags to the flags the lock file is opened with, e.g.
+// O_NOFOLLOW or O_CLOEXEC. The access mode is selected by WithReadOnly.
+func WithOpenFlags(flags int) Option {
+	return func(l *Locker) {
+		l.flags = flags
+	}
+}
+
+// WithReadOnly opens the lock file read-only, which suffices for shared locks.
+// Read-only Lockers can't be locked exclusively or upgraded, see RLock.
+func WithReadOnly() Option {
+	return func(l *Locker) {
+		l.readOnly = true
+	}
+}
+
+// WithDirLockName sets the name of the lock file of directory locks. If the
+// path of the Locker is a directory, the Locker locks the file called name
+// within it, which is created on acquisition. An empty name selects the
+// default ".lock".
+func WithDirLockName(name string) Option {
+	return func(l *Locker) {
+		if name == "" {
+			name = defaultDirLockName
+		}
+		l.dirLockName = name
+	}
+}
+
+// WithDirCleanup enables or disables the removal of the lock file of directory
+// locks on release. The file is only removed by its last holder. Acquisitions
+// verify that the locked file is still the file within the directory and lock
+// the file created by the next acquisition otherwise.
+func WithDirCleanup(enabled bool) Option {
+	return func(l *Locker) {
+		l.dirCleanup = enabled
+	}
+}
+
+// WithRemoveOnUnlock removes the lock file on release, like WithDirCleanup
+// does for directory locks, so that services don't leave lock files behind.
+// The file is created on acquisition then, see WithCreate. Only its last
+// holder removes the file, while holding the lock; acquisitions verify that
+// the locked file is still the file at the path and lock the file created by
+// the next acquisition otherwise, so that a waiter which locked the removed
+// file doesn't proceed. The file must be dedicated to locking. Windows doesn't
+// allow removing open files, they're left behind there.
+func WithRemoveOnUnlock() Option {
+	return func(l *Locker) {
+		l.create = true
+		l.removeOnUnlock = true
+	}
+}
+
+// WithTimeout bounds the time Lock and Acquire block before they return
+// ErrLockTimeout. A zero timeout blocks until the lock is acquired.
+func WithTimeout(timeout time.Duration) Option {
+	return func(l *Locker) {
+		l.timeout = timeout
+	}
+}
+
+// WithPolling enables or disables the polling mode. By default Lock acquires
+// the lock as soon as it's released: it blocks in the kernel, e.g. with
+// F_OFD_SETLKW, or if the acquisition can be cancelled or time out, it retries
+// whenever the lock file is closed. In polling mode Lock retries at the retry
+// interval instead, for file systems whose blocking locks misbehave.
+func WithPolling(enabled bool) Option {
+	return func(l *Locker) {
+		l.polling = enabled
+	}
+}
+
+// WithLogger sets the logger used to report acquisition progress.
+func WithLogger(logger logrus.FieldLogger) Option {
+	return func(l *Locker) {
+		l.logger = logger
+	}
+}
+
+// Configure applies opts to the Locker. It's safe to call Configure while the
+// lock is being acquired; the changes take effect with the next attempt.
+func (l *Locker) Configure(opts ...Option) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, opt := range opts {
+		opt(l)
+	}
+}
+
+// WithFileSystem sets the FileSystem used to open and lock the file. A nil
+// FileSystem selects OSFileSystem. Locks which are already held keep using the
+// FileSystem they were acquired with.
+func WithFileSystem(fs FileSystem) Option {
+	return func(l *Locker) {
+		if fs == nil {
+			fs = OSFileSystem
+		}
+		l.fs = fs
+	}
+}
+
+// WithClock sets the source of time used for timestamps, timeouts, retries
+// and handoff deadlines, e.g. a clock.Fake in tests. A nil Clock selects the
+// default, clock.Precise if it's available.
+func WithClock(c clock.Clock) Option {
+	return func(l *Locker) {
+		if c == nil {
+			c = defaultClock()
+		}
+		l.clock = c
+	}
+}
+
+// WithWaitQueue makes the Locker wait in q for its turn before acquiring the
+// lock, see WaitQueue. Acquisitions by a Scheduler don't take part in the
+// queue.
+func WithWaitQueue(q *WaitQueue) Option {
+	return func(l *Locker) {
+		l.queue = q
+	}
+}
+
+// WithPriority sets the priority of the Locker in a WaitQueue with
+// PolicyPriority. Higher priorities proceed first, the default is zero.
+func WithPriority(priority int) Option {
+	return func(l *Locker) {
+		l.priority = priority
+	}
+}
+
+// WithExpectedHold sets the time the Locker is expected to hold the lock, for
+// a WaitQueue with PolicyShortestHold.
+func WithExpectedHold(d time.Duration) Option {
+	return func(l *Locker) {
+		l.expectedHold = d
+	}
+}
+
+// WithJitter randomizes the wakeups of the Locker within window: retries of
+// Lock, attempts of a Scheduler and the notifications of TryLockOrNotify are
+// delayed by up to window. It keeps many waiters from hitting the file system
+// at once when a contended lock is released. Waiters sharing a WaitQueue are
+// woken up one at a time anyway.
+func WithJitter(window time.Duration) Option {
+	return func(l *Locker) {
+		l.jitter = window
+	}
+}
+
+// WithOwner records the Owner in the lock file on exclusive acquisitions of
+// the whole file, with label describing the holder, e.g. a job name. The
+// record replaces the content of the file, which has to be dedicated to
+// locking. Holder reports the recorded Owner to others.
+func WithOwner(label string) Option {
+	return func(l *Locker) {
+		l.owner = true
+		l.ownerLabel = label
+	}
+}
+
+// WithOwnerKey signs the Owner records of WithOwner with an HMAC of key, and
+// ignores records which aren't signed with it: Holder reports them as
+// unknown, and IsStale and link locks don't break locks they describe as
+// held by a process which is gone. Lockers sharing a lock directory with
+// writers which aren't trusted, e.g. a world writable one, should share a
+// key, see OwnerKeyFromEnv; the key must only be readable by the holders.
+// Leases, see WithLease, are judged by the modification time of the lock
+// file and aren't covered by the signature.
+func WithOwnerKey(key []byte) Option {
+	return func(l *Locker) {
+		l.ownerKey = key
+	}
+}
+
+// WithLease holds the lock under a lease of ttl, for locks coordinating hosts
+// through a shared file system whose locks may outlive a hung or partitioned
+// holder. The holder renews the lease by bumping the modification time of the
+// lock file every third of ttl; waiters take a lock over whose lease wasn't
+// renewed within ttl by replacing the lock file, see ForceUnlock. Holders learn
+// of the loss of their lease through Done. Lockers with a lease poll, see
+// WithPolling, and the clocks of the hosts must agree within a fraction of ttl.
+func WithLease(ttl time.Duration) Option {
+	return func(l *Locker) {
+		l.lease = ttl
+	}
+}
+
+// WithStrategy selects how the Locker locks, see Strategy.
+func WithStrategy(s Strategy) Option {
+	return func(l *Locker) {
+		l.strategy = s
+	}
+}
+
+// WithHooks sets the Hooks observing the acquisitions of the Locker. A nil
+// Hooks selects NopHooks.
+func WithHooks(hooks Hooks) Option {
+	return func(l *Locker) {
+		if hooks == nil {
+			hooks = NopHooks{}
+		}
+		l.hooks = hooks
+	}
+}
+
+// WithLabels attaches labels to the Locker, given as alternating keys and
+// values, e.g. WithLabels("job", "compaction"). The labels are added to the
+// log fields and to the misuse reports of the lockdebug build tag, so that
+// contention can be attributed to workloads. Labels are merged with the
+// labels set before. WithLabels panics if a key lacks a value.
+func WithLabels(kv ...string) Option {
+	if len(kv)%2 != 0 {
+		panic("lock: odd number of label arguments")
+	}
+	return func(l *Locker) {
+		labels := make(map[string]string, len(l.labels)+len(kv)/2)
+		for k, v := range l.labels {
+			labels[k] = v
+		}
+		for i := 0; i < len(kv); i += 2 {
+			labels[kv[i]] = kv[i+1]
+		}
+		l.labels = labels
+	}
+}
@@ -0,0 +1,233 @@
+package lock
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ownerVersion is the version of the Owner record written by WithOwner, the
+// only metadata kept in lock files; leases are kept in their modification
+// time. Later versions only add fields, so that libraries of different
+// versions can share a lock directory: readers decode the fields they know of
+// records of later versions, and migrate content written before Owner
+// records, see legacyOwner.
+const ownerVersion = 1
+
+// OwnerKeyEnv is the environment variable naming the file of the key read by
+// OwnerKeyFromEnv.
+const OwnerKeyEnv = "LOCKLIB_OWNER_KEY_FILE"
+
+// Owner describes the holder of a lock, as recorded in the lock file by
+// WithOwner.
+type Owner struct {
+	Version  int       `json:"version"`
+	PID      int       `json:"pid"`
+	Hostname string    `json:"hostname"`
+	Since    time.Time `json:"since"`
+	Label    string    `json:"label,omitempty"`
+	// Signature is the hex encoded HMAC-SHA256 of the record, see
+	// WithOwnerKey.
+	Signature string `json:"signature,omitempty"`
+}
+
+// Known reports whether the holder recorded itself. Holders not configured
+// with WithOwner leave the Owner unknown.
+func (o *Owner) Known() bool {
+	return o.Version > 0
+}
+
+func (o *Owner) String() string {
+	if !o.Known() {
+		return "unknown holder"
+	}
+	// records migrated from legacy content lack the host and time
+	s := fmt.Sprintf("pid %d", o.PID)
+	if o.Hostname != "" {
+		s += " on host " + o.Hostname
+	}
+	if !o.Since.IsZero() {
+		s += " since " + o.Since.Format(time.RFC3339)
+	}
+	if o.Label != "" {
+		s += " (" + o.Label + ")"
+	}
+	return s
+}
+
+// Holder returns the current holder of the lock, or nil if the lock is free.
+// The Owner is unknown unless the holder was configured with WithOwner, and
+// for shared locks and byte ranges, which aren't recorded. The lock may be
+// acquired or released right after Holder returns, the Owner is meant to
+// diagnose contention, e.g. after TryLock returned ErrLockLocked.
+//
+// Windows doesn't allow reading files locked exclusively by other handles,
+// Holder fails there while the lock is held.
+func (l *Locker) Holder() (*Owner, error) {
+	if l.linked() {
+		fs, file, err := l.openLink()
+		if err != nil || file == nil {
+			return nil, err
+		}
+		defer fs.Close(file)
+		return readOwner(file, l.key())
+	}
+	h, err := l.open()
+	if err != nil {
+		return nil, err
+	}
+	defer h.fs.Close(h.file)
+
+	locked, err := isLocked(h.fs, h.file)
+	if err != nil {
+		return nil, queryFailed(h.path, err)
+	}
+	if !locked {
+		return nil, nil
+	}
+	return readOwner(h.file, l.key())
+}
+
+// holderHint annotates ErrLockLocked with the holder of the lock, if it's
+// known, see Holder. Other errors are returned as is.
+func (l *Locker) holderHint(err error) error {
+	if err != ErrLockLocked {
+		return err
+	}
+	owner, herr := l.Holder()
+	if herr != nil || owner == nil || !owner.Known() {
+		return err
+	}
+	return &HintError{Err: err, Hint: "held by " + owner.String()}
+}
+
+// readOwner reads the Owner recorded in file, the unknown Owner if there's no
+// record. If key isn't nil, records which aren't signed with key are unknown
+// as well.
+func readOwner(file *os.File, key []byte) (*Owner, error) {
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("read owner failed: %w", err)
+	}
+	owner := &Owner{}
+	if len(data) == 0 {
+		return owner, nil
+	}
+	if err := json.Unmarshal(data, owner); err != nil {
+		legacy, ok := legacyOwner(data)
+		if !ok {
+			return nil, fmt.Errorf("decode owner failed: %w", err)
+		}
+		owner = legacy
+	}
+	if key != nil {
+		mac, err := hex.DecodeString(owner.Signature)
+		if err != nil || !hmac.Equal(mac, owner.mac(key)) {
+			return &Owner{}, nil
+		}
+	}
+	return owner, nil
+}
+
+// legacyOwner migrates the content of lock files written before Owner
+// records, the PID of the holder on a line of its own like in PID files, to
+// an Owner of the current version. The host of the holder isn't known, its
+// lock is never stale.
+func legacyOwner(data []byte) (*Owner, bool) {
+	line := strings.TrimSpace(string(data))
+	pid, err := strconv.Atoi(line)
+	if err != nil || pid <= 0 {
+		return nil, false
+	}
+	return &Owner{Version: ownerVersion, PID: pid}, true
+}
+
+// encodeOwner signs owner with key, unless key is nil, and encodes it.
+func encodeOwner(owner *Owner, key []byte) ([]byte, error) {
+	if key != nil {
+		owner.Signature = hex.EncodeToString(owner.mac(key))
+	}
+	data, err := json.Marshal(owner)
+	if err != nil {
+		return nil, fmt.Errorf("encode owner failed: %w", err)
+	}
+	return data, nil
+}
+
+// mac returns the HMAC-SHA256 of the fields of the Owner with key. The fields
+// are hashed in a fixed encoding rather than as JSON, which isn't canonical.
+func (o *Owner) mac(key []byte) []byte {
+	m := hmac.New(sha256.New, key)
+	fmt.Fprintf(m, "%d\x00%d\x00%s\x00%s\x00%s", o.Version, o.PID, o.Hostname, o.Since.UTC().Format(time.RFC3339Nano), o.Label)
+	return m.Sum(nil)
+}
+
+// key returns the key signing the Owner records of the Locker, see
+// WithOwnerKey.
+func (l *Locker) key() []byte {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.ownerKey
+}
+
+// OwnerKeyFromEnv reads the key for WithOwnerKey from the file named by the
+// OwnerKeyEnv variable. Surrounding whitespace of the key is ignored. It
+// returns nil if the variable isn't set.
+func OwnerKeyFromEnv() ([]byte, error) {
+	path := os.Getenv(OwnerKeyEnv)
+	if path == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read owner key failed: %w", err)
+	}
+	key := []byte(strings.TrimSpace(string(data)))
+	if len(key) == 0 {
+		return nil, fmt.Errorf("owner key %s is empty", path)
+	}
+	return key, nil
+}
+
+// record records the Owner in the file of h if the Locker is configured with
+// WithOwner and h holds the whole file exclusively.
+func (l *Locker) record(h *handle) error {
+	l.mu.Lock()
+	owner, label, key := l.owner, l.ownerLabel, l.ownerKey
+	l.mu.Unlock()
+
+	// link locks record their Owner as they're linked
+	if !owner || h.link || h.mode != modeExclusive || h.region != (region{}) {
+		return nil
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("hostname failed: %w", err)
+	}
+	data, err := encodeOwner(&Owner{
+		Version:  ownerVersion,
+		PID:      os.Getpid(),
+		Hostname: hostname,
+		Since:    l.now(),
+		Label:    label,
+	}, key)
+	if err != nil {
+		return err
+	}
+	if err := h.file.Truncate(0); err != nil {
+		return fmt.Errorf("write owner failed: %w", err)
+	}
+	if _, err := h.file.WriteAt(data, 0); err != nil {
+		return fmt.Errorf("write owner failed: %w", err)
+	}
+	h.owned = true
+	return nil
+}
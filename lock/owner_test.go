@@ -0,0 +1,230 @@
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHolder(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("locked files can't be read on windows")
+	}
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	l1, l2 := New(file.Name(), WithOwner("compaction")), New(file.Name())
+	owner, err := l2.Holder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner != nil {
+		t.Fatalf("expected free lock to have no holder, got %v", owner)
+	}
+
+	if err := l1.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	err = l2.TryLock()
+	var hint *HintError
+	if !errors.Is(err, ErrLockLocked) || !errors.As(err, &hint) {
+		t.Fatalf("expected %v with the holder, got %v", ErrLockLocked, err)
+	}
+	if want := fmt.Sprintf("held by pid %d on host", os.Getpid()); !strings.Contains(err.Error(), want) {
+		t.Fatalf("expected %q in %q", want, err)
+	}
+	owner, err = l2.Holder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostname, _ := os.Hostname()
+	switch {
+	case owner == nil || !owner.Known():
+		t.Fatalf("expected known holder, got %v", owner)
+	case owner.Version != ownerVersion:
+		t.Fatalf("expected version %d, got %d", ownerVersion, owner.Version)
+	case owner.PID != os.Getpid():
+		t.Fatalf("expected pid %d, got %d", os.Getpid(), owner.PID)
+	case owner.Hostname != hostname:
+		t.Fatalf("expected host %q, got %q", hostname, owner.Hostname)
+	case owner.Since.After(l1.HeldSince()):
+		t.Fatalf("expected holder since acquisition at %v, got %v", l1.HeldSince(), owner.Since)
+	case owner.Label != "compaction":
+		t.Fatalf("expected label %q, got %q", "compaction", owner.Label)
+	}
+	if s := owner.String(); !strings.Contains(s, "compaction") {
+		t.Fatalf("expected label in %q", s)
+	}
+	if err := l1.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(file.Name()); len(data) != 0 {
+		t.Fatalf("expected record to be removed on release, got %q", data)
+	}
+
+	// holders not recording themselves are unknown
+	if err := l2.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	owner, err = l1.Holder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if owner == nil || owner.Known() {
+		t.Fatalf("expected unknown holder, got %v", owner)
+	}
+	l2.Unlock()
+}
+
+func TestOwnerKey(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("locked files can't be read on windows")
+	}
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	key := []byte("secret")
+	l := New(file.Name(), WithOwner("signed"), WithOwnerKey(key))
+	if err := l.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		key   []byte
+		known bool
+	}{{key, true}, {nil, true}, {[]byte("other"), false}} {
+		owner, err := New(file.Name(), WithOwnerKey(c.key)).Holder()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if owner == nil || owner.Known() != c.known {
+			t.Fatalf("expected holder known %v with key %q, got %v", c.known, c.key, owner)
+		}
+	}
+	if err := l.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a record of a crashed holder crafted without the key isn't trusted
+	hostname, _ := os.Hostname()
+	owner := &Owner{Version: ownerVersion, PID: deadPID(t), Hostname: hostname, Since: time.Now()}
+	data, _ := json.Marshal(owner)
+	if err := ioutil.WriteFile(file.Name(), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := l.IsStale(); err != nil || stale {
+		t.Fatalf("expected unsigned record not to be stale, got %v %v", stale, err)
+	}
+	if stale, err := New(file.Name()).IsStale(); err != nil || !stale {
+		t.Fatalf("expected record to be stale without key, got %v %v", stale, err)
+	}
+	if data, err = encodeOwner(owner, key); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(file.Name(), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := l.IsStale(); err != nil || !stale {
+		t.Fatalf("expected signed record to be stale, got %v %v", stale, err)
+	}
+}
+
+func TestOwnerKeyFromEnv(t *testing.T) {
+	defer os.Unsetenv(OwnerKeyEnv)
+
+	os.Unsetenv(OwnerKeyEnv)
+	if key, err := OwnerKeyFromEnv(); err != nil || key != nil {
+		t.Fatalf("expected no key, got %q %v", key, err)
+	}
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("secret\n")
+	file.Close()
+
+	os.Setenv(OwnerKeyEnv, file.Name())
+	if key, err := OwnerKeyFromEnv(); err != nil || string(key) != "secret" {
+		t.Fatalf("expected key %q, got %q %v", "secret", key, err)
+	}
+	os.Setenv(OwnerKeyEnv, file.Name()+".missing")
+	if _, err := OwnerKeyFromEnv(); err == nil {
+		t.Fatal("expected missing key file to fail")
+	}
+}
+
+func TestOwnerVersions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("locked files can't be read on windows")
+	}
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	l := New(file.Name())
+	if err := l.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Unlock()
+
+	// records of later versions are decoded as far as they're known
+	future := `{"version":7,"pid":42,"hostname":"host","since":"2020-01-01T00:00:00Z","lease":{"ttl":"1m"}}`
+	if err := ioutil.WriteFile(file.Name(), []byte(future), 0600); err != nil {
+		t.Fatal(err)
+	}
+	owner, err := New(file.Name()).Holder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !owner.Known() || owner.Version != 7 || owner.PID != 42 || owner.Hostname != "host" {
+		t.Fatalf("expected record of version 7, got %+v", owner)
+	}
+
+	// legacy content is the PID of the holder
+	if err := ioutil.WriteFile(file.Name(), []byte("42\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	owner, err = New(file.Name()).Holder()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !owner.Known() || owner.Version != ownerVersion || owner.PID != 42 || owner.Hostname != "" {
+		t.Fatalf("expected migrated record of pid 42, got %+v", owner)
+	}
+	if s := owner.String(); s != "pid 42" {
+		t.Fatalf("expected %q, got %q", "pid 42", s)
+	}
+	l.Unlock()
+
+	// the host of legacy holders isn't known, they're never stale
+	if err := ioutil.WriteFile(file.Name(), []byte(fmt.Sprintf("%d\n", deadPID(t))), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := New(file.Name()).IsStale(); err != nil || stale {
+		t.Fatalf("expected legacy record not to be stale, got %v %v", stale, err)
+	}
+
+	if err := ioutil.WriteFile(file.Name(), []byte("garbage"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(file.Name()).IsStale(); err == nil {
+		t.Fatal("expected undecodable record to fail")
+	}
+}
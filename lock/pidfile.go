@@ -0,0 +1,109 @@
+package lock
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PIDFile is a PID file held locked by the running instance of a program. The
+// lock is released when the process exits, however it exits, so a PID file
+// left behind doesn't keep the next instance from starting.
+type PIDFile struct {
+	locker *Locker
+}
+
+// NewPIDFile returns the PIDFile at path, locked by a Locker configured by
+// opts. The file is created on Acquire if it doesn't exist.
+func NewPIDFile(path string, opts ...Option) *PIDFile {
+	opts = append([]Option{WithCreate(0644)}, opts...)
+	return &PIDFile{locker: New(path, opts...)}
+}
+
+// Path returns the path of the PID file.
+func (p *PIDFile) Path() string {
+	return p.locker.Path()
+}
+
+// Acquire locks the PID file and writes the PID of the process into it. If
+// another instance holds the file, Acquire returns ErrRunning with its PID.
+func (p *PIDFile) Acquire() error {
+	if err := p.locker.TryLock(); err != nil {
+		if !errors.Is(err, ErrLockLocked) {
+			return err
+		}
+		pid, _, _ := p.Check()
+		return fmt.Errorf("pid %d: %w", pid, ErrRunning)
+	}
+	// the PID is written over the previous content, which is cut off after
+	// the line of the PID, readers of the first line never see a mix
+	data := []byte(strconv.Itoa(os.Getpid()) + "\n")
+	file := p.locker.File()
+	_, err := file.WriteAt(data, 0)
+	if err == nil {
+		err = file.Truncate(int64(len(data)))
+	}
+	if err != nil {
+		p.locker.Unlock()
+		return fmt.Errorf("write pid failed: %w", err)
+	}
+	return nil
+}
+
+// Check reports whether an instance holds the PID file and returns its PID,
+// without acquiring the lock. The holder may be the calling process. Windows
+// doesn't allow reading files locked by other handles, the PID of the holder
+// is zero there.
+func (p *PIDFile) Check() (int, bool, error) {
+	abs, err := filepath.Abs(p.locker.Path())
+	if err != nil {
+		return 0, false, fmt.Errorf("absolute represenation of path failed: %w", err)
+	}
+	p.locker.mu.Lock()
+	fs := p.locker.fs
+	p.locker.mu.Unlock()
+
+	file, err := fs.OpenFile(abs, os.O_RDONLY, 0)
+	if os.IsNotExist(err) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, openFailed(abs, err)
+	}
+	defer fs.Close(file)
+
+	locked, err := isLocked(fs, file)
+	if err != nil {
+		return 0, false, queryFailed(abs, err)
+	}
+	if !locked {
+		return 0, false, nil
+	}
+	line, err := bufio.NewReader(file).ReadString('\n')
+	if err != nil {
+		// the holder didn't write its PID yet or the file can't be read
+		return 0, true, nil
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil {
+		return 0, true, fmt.Errorf("parse pid failed: %w", err)
+	}
+	return pid, true, nil
+}
+
+// Release truncates the PID file and releases the lock.
+func (p *PIDFile) Release() error {
+	file := p.locker.File()
+	if file == nil {
+		return ErrNotLocked
+	}
+	if err := file.Truncate(0); err != nil {
+		p.locker.Unlock()
+		return fmt.Errorf("truncate pid failed: %w", err)
+	}
+	return p.locker.Unlock()
+}
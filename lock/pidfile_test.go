@@ -0,0 +1,69 @@
+package lock
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPIDFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("locked files can't be read on windows")
+	}
+	dir, err := ioutil.TempDir("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "daemon.pid")
+
+	p1, p2 := NewPIDFile(path), NewPIDFile(path)
+	if pid, running, err := p2.Check(); err != nil || running || pid != 0 {
+		t.Fatalf("expected missing PID file not to be running, got %d %v %v", pid, running, err)
+	}
+
+	// a PID file left behind by a stale instance is taken over
+	if err := ioutil.WriteFile(path, []byte("4194304123\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, running, err := p2.Check(); err != nil || running {
+		t.Fatalf("expected stale PID file not to be running, got %v %v", running, err)
+	}
+	if err := p1.Acquire(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strconv.Itoa(os.Getpid()) + "\n"; string(data) != want {
+		t.Fatalf("expected %q, got %q", want, data)
+	}
+
+	pid, running, err := p2.Check()
+	if err != nil || !running || pid != os.Getpid() {
+		t.Fatalf("expected instance %d to be running, got %d %v %v", os.Getpid(), pid, running, err)
+	}
+	err = p2.Acquire()
+	if !errors.Is(err, ErrRunning) || !strings.Contains(err.Error(), strconv.Itoa(os.Getpid())) {
+		t.Fatalf("expected %v with pid, got %v", ErrRunning, err)
+	}
+
+	if err := p1.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(path); len(data) != 0 {
+		t.Fatalf("expected released PID file to be empty, got %q", data)
+	}
+	if _, running, err := p2.Check(); err != nil || running {
+		t.Fatalf("expected released PID file not to be running, got %v %v", running, err)
+	}
+	if err := p1.Release(); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("expected release of released PID file to fail, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package lock
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// PrivilegeError is returned by CheckPrivilegeDrop if locks won't work after
+// dropping privileges.
+type PrivilegeError struct {
+	UID int
+	GID int
+
+	// Problems maps the paths of the affected locks to the reason. The
+	// process credentials are reported with an empty path.
+	Problems map[string]string
+}
+
+func (e *PrivilegeError) Error() string {
+	paths := make([]string, 0, len(e.Problems))
+	for path := range e.Problems {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	reasons := make([]string, 0, len(paths))
+	for _, path := range paths {
+		if path == "" {
+			reasons = append(reasons, e.Problems[path])
+			continue
+		}
+		reasons = append(reasons, path+": "+e.Problems[path])
+	}
+	return fmt.Sprintf("lock: locks unusable as %d:%d: %s", e.UID, e.GID, strings.Join(reasons, "; "))
+}
+
+// CheckPrivilegeDrop verifies after a setuid/setgid privilege drop that the
+// process runs as uid and gid, that the files of all held locks can still be
+// opened for reading and writing and that the locks at paths, which are
+// acquired later, are accessible. It returns a *PrivilegeError listing every
+// problem.
+//
+// Held locks are released by closing their descriptor, which doesn't need
+// privileges. Reopening their files is required to inspect or renew them
+// though, e.g. by Ping.
+func CheckPrivilegeDrop(uid, gid int, paths ...string) error {
+	e := &PrivilegeError{UID: uid, GID: gid, Problems: make(map[string]string)}
+
+	euid, egid := os.Geteuid(), os.Getegid()
+	if euid != uid || egid != gid {
+		e.Problems[""] = fmt.Sprintf("process runs as %d:%d, the privileges weren't dropped", euid, egid)
+	}
+	for _, path := range heldPaths() {
+		if reason := checkAccess(path, euid, egid); reason != "" {
+			e.Problems[path] = "held lock " + reason
+		}
+	}
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			e.Problems[path] = err.Error()
+			continue
+		}
+		if reason := checkAccess(abs, euid, egid); reason != "" {
+			e.Problems[abs] = reason
+		}
+	}
+	if len(e.Problems) > 0 {
+		return e
+	}
+	return nil
+}
+
+// checkAccess returns why the file at path can't be locked by the process or
+// an empty string if it can.
+func checkAccess(path string, uid, gid int) string {
+	// opening another description doesn't affect held OFD locks
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err == nil {
+		file.Close()
+		return ""
+	}
+	if os.IsNotExist(err) {
+		return "file doesn't exist"
+	}
+	reason := diagnose("open", path, err).Error()
+	fi, serr := os.Stat(path)
+	if serr != nil {
+		return reason
+	}
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && (int(st.Uid) != uid || int(st.Gid) != gid) {
+		reason += fmt.Sprintf(", chown the file to %d:%d or make it accessible before dropping privileges", uid, gid)
+	}
+	return reason
+}
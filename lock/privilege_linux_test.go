@@ -0,0 +1,49 @@
+package lock
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckPrivilegeDrop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "held")
+	if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	l := New(path)
+	if err := l.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.Unlock()
+
+	uid, gid := os.Geteuid(), os.Getegid()
+	if err := CheckPrivilegeDrop(uid, gid, path); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Remove(path)
+	missing := filepath.Join(dir, "missing")
+	err = CheckPrivilegeDrop(uid+1, gid, missing)
+	var perr *PrivilegeError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected *PrivilegeError, got %v", err)
+	}
+	if len(perr.Problems) != 3 {
+		t.Fatalf("expected 3 problems, got %v", perr.Problems)
+	}
+	if !strings.Contains(perr.Problems[""], "weren't dropped") ||
+		perr.Problems[path] != "held lock file doesn't exist" ||
+		perr.Problems[missing] != "file doesn't exist" {
+		t.Fatalf("unexpected problems %v", perr.Problems)
+	}
+}
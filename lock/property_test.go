@@ -0,0 +1,24 @@
+package lock_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/peertechde/lib/lock"
+	"github.com/peertechde/lib/lock/locktest"
+)
+
+func TestProperties(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	locktest.CheckProperties(t, locktest.PropertyConfig{}, func() lock.Interface {
+		return lock.New(file.Name(), lock.WithRetryInterval(time.Millisecond))
+	})
+}
@@ -0,0 +1,166 @@
+package lock
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Policy selects the waiter of a WaitQueue which proceeds once a lock frees.
+type Policy int
+
+const (
+	// PolicyFIFO lets the waiters proceed in order of arrival.
+	PolicyFIFO Policy = iota
+	// PolicyPriority lets the waiter with the highest priority proceed, see
+	// WithPriority. Waiters with the same priority proceed in order of
+	// arrival.
+	PolicyPriority
+	// PolicyShortestHold lets the waiter expecting to hold the lock for the
+	// shortest time proceed, see WithExpectedHold. Waiters without an
+	// expectation go last.
+	PolicyShortestHold
+)
+
+// WaitQueue orders the acquisitions of Lockers of the same process. Of the
+// Lockers sharing a WaitQueue only one per path at a time tries to acquire or
+// holds the lock, the others wait in the queue. When the lock frees, the
+// policy of the queue determines which waiter proceeds, instead of the
+// waiters racing for it.
+type WaitQueue struct {
+	policy Policy
+
+	mu    sync.Mutex
+	seq   uint64
+	paths map[string]*pathQueue
+}
+
+type pathQueue struct {
+	busy    bool
+	waiters []*waiter
+}
+
+type waiter struct {
+	seq      uint64
+	priority int
+	hold     time.Duration
+	granted  chan struct{}
+}
+
+// NewWaitQueue returns a WaitQueue with policy, see WithWaitQueue.
+func NewWaitQueue(policy Policy) *WaitQueue {
+	return &WaitQueue{policy: policy, paths: make(map[string]*pathQueue)}
+}
+
+// Waiting returns the number of Lockers waiting for the lock at path, which
+// must be absolute.
+func (q *WaitQueue) Waiting(path string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if pq, ok := q.paths[path]; ok {
+		return len(pq.waiters)
+	}
+	return 0
+}
+
+// wait returns once it's the turn of the caller to acquire the lock at path.
+// The returned function passes the turn on, it must be called once the lock
+// is released or couldn't be acquired.
+func (q *WaitQueue) wait(ctx context.Context, expired <-chan time.Time, path string, priority int, hold time.Duration, block bool) (func(), error) {
+	q.mu.Lock()
+	pq, ok := q.paths[path]
+	if !ok {
+		pq = &pathQueue{}
+		q.paths[path] = pq
+	}
+	if !pq.busy {
+		pq.busy = true
+		q.mu.Unlock()
+		return q.turn(path), nil
+	}
+	if !block {
+		q.mu.Unlock()
+		return nil, ErrLockLocked
+	}
+	q.seq++
+	w := &waiter{seq: q.seq, priority: priority, hold: hold, granted: make(chan struct{})}
+	pq.waiters = append(pq.waiters, w)
+	q.mu.Unlock()
+
+	var err error
+	select {
+	case <-w.granted:
+		return q.turn(path), nil
+	case <-ctx.Done():
+		err = cancelled(ctx.Err())
+	case <-expired:
+		err = ErrLockTimeout
+	}
+
+	q.mu.Lock()
+	for i, other := range pq.waiters {
+		if other == w {
+			pq.waiters = append(pq.waiters[:i], pq.waiters[i+1:]...)
+			q.mu.Unlock()
+			return nil, err
+		}
+	}
+	q.mu.Unlock()
+	// granted concurrently, pass the turn on
+	q.turn(path)()
+	return nil, err
+}
+
+// turn returns the function passing the turn for path on to the next waiter.
+func (q *WaitQueue) turn(path string) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+
+			pq := q.paths[path]
+			if len(pq.waiters) == 0 {
+				delete(q.paths, path)
+				return
+			}
+			i := q.next(pq.waiters)
+			w := pq.waiters[i]
+			pq.waiters = append(pq.waiters[:i], pq.waiters[i+1:]...)
+			close(w.granted)
+		})
+	}
+}
+
+// next returns the index of the waiter proceeding according to the policy.
+func (q *WaitQueue) next(waiters []*waiter) int {
+	before := func(a, b *waiter) bool {
+		switch q.policy {
+		case PolicyPriority:
+			if a.priority != b.priority {
+				return a.priority > b.priority
+			}
+		case PolicyShortestHold:
+			ah, bh := a.hold, b.hold
+			if ah <= 0 {
+				ah = math.MaxInt64
+			}
+			if bh <= 0 {
+				bh = math.MaxInt64
+			}
+			if ah != bh {
+				return ah < bh
+			}
+		}
+		return a.seq < b.seq
+	}
+	best := 0
+	for i, w := range waiters {
+		if before(w, waiters[best]) {
+			best = i
+		}
+	}
+	return best
+}
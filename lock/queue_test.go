@@ -0,0 +1,135 @@
+package lock
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWaitQueue(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+	path, err := filepath.Abs(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type waiter struct {
+		name     string
+		priority int
+		hold     time.Duration
+	}
+	waiters := []waiter{
+		{name: "a", priority: 1, hold: 0},
+		{name: "b", priority: 3, hold: 30 * time.Millisecond},
+		{name: "c", priority: 2, hold: 10 * time.Millisecond},
+		{name: "d", priority: 3, hold: 20 * time.Millisecond},
+	}
+	tests := []struct {
+		policy Policy
+		order  []string
+	}{
+		{policy: PolicyFIFO, order: []string{"a", "b", "c", "d"}},
+		{policy: PolicyPriority, order: []string{"b", "d", "c", "a"}},
+		{policy: PolicyShortestHold, order: []string{"c", "d", "b", "a"}},
+	}
+	for _, test := range tests {
+		q := NewWaitQueue(test.policy)
+		holder := New(file.Name())
+		holder.Configure(WithWaitQueue(q))
+		if err := holder.Lock(); err != nil {
+			t.Fatal(err)
+		}
+
+		// local waiters don't contend while the lock is held in the queue
+		other := New(file.Name())
+		other.Configure(WithWaitQueue(q))
+		if err := other.TryLock(); err != ErrLockLocked {
+			t.Fatalf("expected %v, got %v", ErrLockLocked, err)
+		}
+
+		acquired := make(chan string, len(waiters))
+		for i, w := range waiters {
+			l := New(file.Name(), WithRetryInterval(time.Millisecond))
+			l.Configure(WithWaitQueue(q), WithPriority(w.priority), WithExpectedHold(w.hold))
+			go func(name string) {
+				g, err := l.Acquire(context.Background())
+				if err != nil {
+					t.Error(err)
+					acquired <- ""
+					return
+				}
+				acquired <- name
+				g.Release()
+			}(w.name)
+			for q.Waiting(path) != i+1 {
+				time.Sleep(time.Millisecond)
+			}
+		}
+
+		if err := holder.Unlock(); err != nil {
+			t.Fatal(err)
+		}
+		var order []string
+		for range waiters {
+			select {
+			case name := <-acquired:
+				order = append(order, name)
+			case <-time.After(2 * time.Second):
+				t.Fatalf("waiters didn't acquire the lock, got %v", order)
+			}
+		}
+		if !reflect.DeepEqual(order, test.order) {
+			t.Fatalf("policy %d: expected order %v, got %v", test.policy, test.order, order)
+		}
+		if q.Waiting(path) != 0 {
+			t.Fatalf("expected empty queue, got %d waiters", q.Waiting(path))
+		}
+	}
+}
+
+func TestWaitQueueCancel(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	q := NewWaitQueue(PolicyFIFO)
+	holder := New(file.Name())
+	holder.Configure(WithWaitQueue(q))
+	if err := holder.Lock(); err != nil {
+		t.Fatal(err)
+	}
+
+	waiter := New(file.Name())
+	waiter.Configure(WithWaitQueue(q), WithTimeout(20*time.Millisecond))
+	if err := waiter.Lock(); err != ErrLockTimeout {
+		t.Fatalf("expected %v, got %v", ErrLockTimeout, err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := waiter.Acquire(ctx); err == nil {
+		t.Fatal("expected cancelled acquisition to fail")
+	}
+
+	// the turn is passed on after the release
+	if err := holder.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := waiter.TryLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := waiter.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,41 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// LockRange locks the length bytes of the file starting at offset, exclusively
+// or shared. Locks of disjoint ranges don't conflict, processes can lock
+// separate segments of the same file at the same time; a zero length extends
+// the range to the end of the file, however far it grows. Lock conflicts with
+// every range. LockRange retries like Lock while the range is locked.
+//
+// A Locker holds a single range, use a Locker per range to lock several of
+// them. Byte ranges aren't supported by the flock backend.
+func (l *Locker) LockRange(offset, length int64, exclusive bool) error {
+	return l.lockRange(offset, length, exclusive, true)
+}
+
+// TryLockRange locks the range like LockRange without blocking. It returns
+// ErrLockLocked if the range is locked.
+func (l *Locker) TryLockRange(offset, length int64, exclusive bool) error {
+	return l.lockRange(offset, length, exclusive, false)
+}
+
+func (l *Locker) lockRange(offset, length int64, exclusive, block bool) error {
+	if offset < 0 || length < 0 {
+		return fmt.Errorf("invalid range: %w", os.ErrInvalid)
+	}
+	mode := modeShared
+	if exclusive {
+		mode = modeExclusive
+	}
+	debugLock(l)
+	h, err := l.acquire(context.Background(), mode, region{offset: offset, len: length}, block)
+	if err != nil {
+		return err
+	}
+	return l.hold(h)
+}
@@ -0,0 +1,71 @@
+package lock
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLockRange(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	l1, l2, l3 := New(file.Name()), New(file.Name()), New(file.Name())
+	if l1.Backend() == "flock" {
+		t.Skip("flock can't lock byte ranges")
+	}
+	if err := l1.TryLockRange(-1, 10, true); !errors.Is(err, os.ErrInvalid) {
+		t.Fatalf("expected %v, got %v", os.ErrInvalid, err)
+	}
+
+	// disjoint ranges don't conflict
+	if err := l1.TryLockRange(0, 10, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := l2.TryLockRange(10, 10, true); err != nil {
+		t.Fatalf("expected disjoint range to be free, got %v", err)
+	}
+
+	// overlapping ranges and the whole file do
+	if err := l3.TryLockRange(5, 10, false); err != ErrLockLocked {
+		t.Fatalf("expected overlapping range to be locked, got %v", err)
+	}
+	if err := l3.TryLock(); err != ErrLockLocked {
+		t.Fatalf("expected file to be locked, got %v", err)
+	}
+	// a range reaching the end of the file overlaps every later range
+	if err := l3.TryLockRange(15, 0, true); err != ErrLockLocked {
+		t.Fatalf("expected open range to be locked, got %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- l3.LockRange(5, 10, true)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if err := l1.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("acquired range overlapping a locked range: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if err := l2.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := l1.TryLockRange(0, 5, true); err != nil {
+		t.Fatalf("expected range before the locked range to be free, got %v", err)
+	}
+	l1.Unlock()
+	l3.Unlock()
+}
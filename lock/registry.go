@@ -0,0 +1,146 @@
+package lock
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config configures a lock opened with Open.
+type Config struct {
+	// Path identifies the lock. File based backends lock the file at Path,
+	// other backends interpret it as the name of the lock.
+	Path string
+
+	// RetryInterval is the interval between acquisition attempts of Lock if
+	// the backend polls. A zero interval selects the default of the backend.
+	RetryInterval time.Duration
+
+	// Timeout bounds the time Lock blocks. A zero timeout blocks until the
+	// lock is acquired.
+	Timeout time.Duration
+
+	// URL is the URI the lock was opened with by OpenURI, if any.
+	URL *url.URL
+
+	// Params holds backend specific parameters. The file lock of the platform
+	// accepts create, the octal permissions of WithCreate, owner, the label of
+	// WithOwner, lease, the TTL of WithLease, and polling, see WithPolling, and
+	// rejects unknown parameters.
+	Params map[string]string
+}
+
+// Factory creates a lock of a backend from config.
+type Factory func(config Config) (Interface, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = make(map[Backend]Factory)
+)
+
+func init() {
+	RegisterBackend(fileBackend, func(config Config) (Interface, error) {
+		opts, err := fileOptions(config.Params)
+		if err != nil {
+			return nil, err
+		}
+		l := New(config.Path, WithRetryInterval(config.RetryInterval))
+		l.Configure(append(opts, WithTimeout(config.Timeout))...)
+		return l, nil
+	})
+}
+
+// fileOptions returns the options of the Params of the file backend, see
+// Config.
+func fileOptions(params map[string]string) ([]Option, error) {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var (
+		opts    []Option
+		unknown []string
+	)
+	for _, key := range keys {
+		value := params[key]
+		switch key {
+		case "create":
+			perm, err := strconv.ParseUint(value, 8, 32)
+			if err != nil || perm > uint64(os.ModePerm) {
+				return nil, fmt.Errorf("invalid create parameter %q", value)
+			}
+			opts = append(opts, WithCreate(os.FileMode(perm)))
+		case "owner":
+			opts = append(opts, WithOwner(value))
+		case "lease":
+			ttl, err := time.ParseDuration(value)
+			if err != nil || ttl <= 0 {
+				return nil, fmt.Errorf("invalid lease parameter %q", value)
+			}
+			opts = append(opts, WithLease(ttl))
+		case "polling":
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid polling parameter: %w", err)
+			}
+			opts = append(opts, WithPolling(enabled))
+		default:
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown parameters %s", strings.Join(unknown, ", "))
+	}
+	return opts, nil
+}
+
+// RegisterBackend makes a backend available by name to Open. It panics if
+// factory is nil or a backend with the same name is already registered.
+func RegisterBackend(name Backend, factory Factory) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if factory == nil {
+		panic("lock: register backend with nil factory")
+	}
+	if _, ok := backends[name]; ok {
+		panic("lock: register backend twice for " + string(name))
+	}
+	backends[name] = factory
+}
+
+// Backends returns the sorted names of the registered backends.
+func Backends() []Backend {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	names := make([]Backend, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// Open returns a lock of the backend registered as name.
+func Open(name Backend, config Config) (Interface, error) {
+	backendsMu.RLock()
+	factory, ok := backends[name]
+	backendsMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown backend %q", name)
+	}
+	l, err := factory(config)
+	if err != nil {
+		return nil, fmt.Errorf("open %s backend failed: %w", name, err)
+	}
+	return l, nil
+}
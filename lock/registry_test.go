@@ -0,0 +1,114 @@
+package lock
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testBackend struct {
+	config Config
+}
+
+func (b *testBackend) Lock() error                              { return nil }
+func (b *testBackend) LockContext(ctx context.Context) error    { return nil }
+func (b *testBackend) TryLock() error                           { return nil }
+func (b *testBackend) TryLockContext(ctx context.Context) error { return nil }
+func (b *testBackend) Unlock() error                            { return nil }
+
+func TestRegistry(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	// built-in backend
+	l, err := Open(BackendOFD, Config{Path: file.Name()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := l.(*Locker); !ok {
+		t.Fatalf("expected *Locker, got %T", l)
+	}
+
+	// registered backend
+	RegisterBackend("test", func(config Config) (Interface, error) {
+		return &testBackend{config: config}, nil
+	})
+	l, err = Open("test", Config{Path: "name", Params: map[string]string{"key": "value"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, ok := l.(*testBackend)
+	if !ok {
+		t.Fatalf("expected *testBackend, got %T", l)
+	}
+	if b.config.Path != "name" || b.config.Params["key"] != "value" {
+		t.Fatalf("unexpected config %+v", b.config)
+	}
+
+	found := false
+	for _, name := range Backends() {
+		found = found || name == "test"
+	}
+	if !found {
+		t.Fatalf("expected test backend in %v", Backends())
+	}
+
+	if _, err := Open("unknown", Config{}); err == nil {
+		t.Fatal("expected unknown backend to fail")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected duplicate registration to panic")
+		}
+	}()
+	RegisterBackend("test", func(config Config) (Interface, error) { return nil, nil })
+}
+
+func TestFileParams(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	l, err := Open(fileBackend, Config{Path: file.Name(), Params: map[string]string{
+		"create":  "0640",
+		"owner":   "compaction",
+		"lease":   "1m",
+		"polling": "true",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	locker := l.(*Locker)
+	switch {
+	case !locker.create || locker.perm != 0640:
+		t.Fatalf("expected lock file to be created with 0640, got %v %v", locker.create, locker.perm)
+	case !locker.owner || locker.ownerLabel != "compaction":
+		t.Fatalf("expected owner %q, got %v %q", "compaction", locker.owner, locker.ownerLabel)
+	case locker.lease != time.Minute:
+		t.Fatalf("expected lease %s, got %s", time.Minute, locker.lease)
+	case !locker.polling:
+		t.Fatal("expected polling")
+	}
+
+	// every unknown parameter is named
+	_, err = Open(fileBackend, Config{Path: file.Name(), Params: map[string]string{"ttl": "1m", "scope": "global"}})
+	if err == nil || !strings.Contains(err.Error(), "unknown parameters scope, ttl") {
+		t.Fatalf("expected unknown parameters scope and ttl, got %v", err)
+	}
+	for key, value := range map[string]string{"create": "rw", "lease": "soon", "polling": "often"} {
+		if _, err := Open(fileBackend, Config{Path: file.Name(), Params: map[string]string{key: value}}); err == nil {
+			t.Fatalf("expected invalid %s parameter %q to fail", key, value)
+		}
+	}
+}
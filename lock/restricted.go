@@ -0,0 +1,42 @@
+package lock
+
+import (
+	"errors"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/peertechde/lib/log"
+)
+
+// restricted is 1 if the restricted mode is enabled, see SetRestricted.
+var restricted int32
+
+// SetRestricted enables or disables the restricted mode. In restricted mode
+// the package avoids the syscalls commonly blocked in sandboxes, e.g. by
+// seccomp or Landlock: it doesn't read /proc, doesn't watch files with inotify
+// and doesn't read extended attributes. The mode is enabled automatically as
+// soon as one of these syscalls fails with EPERM or ENOSYS.
+func SetRestricted(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&restricted, v)
+}
+
+// Restricted reports whether the restricted mode is enabled.
+func Restricted() bool {
+	return atomic.LoadInt32(&restricted) == 1
+}
+
+// restrict enables the restricted mode if err shows that op was blocked by a
+// sandbox and reports whether it did.
+func restrict(op string, err error) bool {
+	if !errors.Is(err, syscall.EPERM) && !errors.Is(err, syscall.ENOSYS) {
+		return false
+	}
+	if atomic.CompareAndSwapInt32(&restricted, 0, 1) {
+		log.Logger.WithField("op", op).WithError(err).Info("syscall blocked, enabling restricted mode")
+	}
+	return true
+}
@@ -0,0 +1,25 @@
+package lock
+
+import (
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestRestricted(t *testing.T) {
+	defer SetRestricted(false)
+
+	if Restricted() {
+		t.Fatal("expected restricted mode to be disabled by default")
+	}
+	if restrict("open", &os.PathError{Op: "open", Path: "name", Err: syscall.EACCES}) || Restricted() {
+		t.Fatal("expected EACCES not to enable the restricted mode")
+	}
+	if !restrict("getxattr", syscall.ENOSYS) || !Restricted() {
+		t.Fatal("expected ENOSYS to enable the restricted mode")
+	}
+	SetRestricted(false)
+	if !restrict("read", &os.PathError{Op: "open", Path: "/proc/self/attr/current", Err: syscall.EPERM}) || !Restricted() {
+		t.Fatal("expected EPERM to enable the restricted mode")
+	}
+}
@@ -0,0 +1,146 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// upgrading holds the paths of the locks a Locker of the process is upgrading.
+var upgrading = struct {
+	sync.Mutex
+	paths map[string]bool
+}{paths: make(map[string]bool)}
+
+// RLock acquires the lock shared. Shared locks exclude the exclusive lock of
+// Lock but not each other, any number of Lockers can hold the lock shared at
+// the same time. RLock retries like Lock while the lock is held exclusively.
+func (l *Locker) RLock() error {
+	debugLock(l)
+	h, err := l.acquire(context.Background(), modeShared, region{}, true)
+	if err != nil {
+		return err
+	}
+	return l.hold(h)
+}
+
+// TryRLock acquires the lock shared like RLock without blocking. It returns
+// ErrLockLocked if the lock is held exclusively.
+func (l *Locker) TryRLock() error {
+	debugLock(l)
+	h, err := l.acquire(context.Background(), modeShared, region{}, false)
+	if err != nil {
+		return err
+	}
+	return l.hold(h)
+}
+
+// Upgrade converts the shared lock held by the Locker into an exclusive lock,
+// without releasing it in between. It retries like Lock while other Lockers
+// hold the lock shared, until the timeout of the Locker expires. If Upgrade
+// fails, the lock is still held shared.
+//
+// Two Lockers holding the lock shared and upgrading at the same time wait for
+// each other. Within a process, the second Upgrade returns ErrLockDeadlock
+// instead; the Locker should unlock to let the first one proceed. Across
+// processes only the timeout resolves the deadlock.
+//
+// Shared locks can't be upgraded on Windows and by the flock backend.
+func (l *Locker) Upgrade() error {
+	l.mu.Lock()
+	h, readOnly := l.held, l.readOnly
+	exclusive := h != nil && h.mode == modeExclusive
+	l.mu.Unlock()
+	if h == nil {
+		return ErrNotLocked
+	}
+	if exclusive {
+		return nil
+	}
+	if readOnly {
+		return errReadOnly
+	}
+	if !beginUpgrade(h.path) {
+		return ErrLockDeadlock
+	}
+	defer endUpgrade(h.path)
+
+	l.mu.Lock()
+	clk, timeout := l.clock, l.timeout
+	l.mu.Unlock()
+
+	expired, stop := expiry(clk, timeout)
+	defer stop()
+	convert := func() error {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		// the descriptor of a released lock may be reused already
+		if l.held != h {
+			return ErrNotLocked
+		}
+		if err := convertLock(h.fs, h.file, modeExclusive, h.region); err != nil {
+			return err
+		}
+		h.mode = modeExclusive
+		return nil
+	}
+	return l.retry(context.Background(), expired, clk, h.path, true, convert)
+}
+
+// Downgrade converts the exclusive lock held by the Locker into a shared lock,
+// without releasing it in between. Lockers waiting to acquire the lock shared
+// proceed afterwards.
+//
+// Locks can't be downgraded by the flock backend.
+func (l *Locker) Downgrade() error {
+	l.mu.Lock()
+	h := l.held
+	if h == nil {
+		l.mu.Unlock()
+		return ErrNotLocked
+	}
+	if h.mode == modeShared {
+		l.mu.Unlock()
+		return nil
+	}
+	if h.link {
+		l.mu.Unlock()
+		return fmt.Errorf("downgrade failed: %w", errLinkLock)
+	}
+	if err := convertLock(h.fs, h.file, modeShared, h.region); err != nil {
+		l.mu.Unlock()
+		return lockFailed(h.path, err)
+	}
+	h.mode = modeShared
+	turn := h.turn
+	h.turn = nil
+	l.mu.Unlock()
+
+	if turn != nil {
+		// the next waiter of the WaitQueue may proceed
+		turn()
+	}
+	return nil
+}
+
+// beginUpgrade registers an upgrade of the lock at path. It reports false if
+// another Locker of the process is upgrading the lock, which can't succeed
+// while the caller holds the lock shared.
+func beginUpgrade(path string) bool {
+	upgrading.Lock()
+	defer upgrading.Unlock()
+
+	if upgrading.paths[path] {
+		return false
+	}
+	upgrading.paths[path] = true
+	return true
+}
+
+func endUpgrade(path string) {
+	upgrading.Lock()
+	defer upgrading.Unlock()
+
+	delete(upgrading.paths, path)
+}
@@ -0,0 +1,246 @@
+package lock
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+const rwChildEnv = "LOCK_TEST_RW_CHILD"
+
+func TestRLock(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	r1, r2, w := New(file.Name()), New(file.Name()), New(file.Name())
+	if err := r1.TryRLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r2.TryRLock(); err != nil {
+		t.Fatalf("expected readers to share the lock, got %v", err)
+	}
+	if err := w.TryLock(); err != ErrLockLocked {
+		t.Fatalf("expected writer to be excluded, got %v", err)
+	}
+	r1.Unlock()
+	if err := w.TryLock(); err != ErrLockLocked {
+		t.Fatalf("expected writer to be excluded by remaining reader, got %v", err)
+	}
+	r2.Unlock()
+
+	if err := w.TryLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r1.TryRLock(); err != ErrLockLocked {
+		t.Fatalf("expected reader to be excluded, got %v", err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- r1.RLock()
+	}()
+	time.Sleep(50 * time.Millisecond)
+	w.Unlock()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	r1.Unlock()
+}
+
+func TestUpgrade(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	r1, r2 := New(file.Name(), WithRetryInterval(10*time.Millisecond)), New(file.Name(), WithRetryInterval(10*time.Millisecond))
+	if err := r1.Upgrade(); err == nil {
+		t.Fatal("expected upgrade of unlocked Locker to fail")
+	}
+	if err := r1.RLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r2.RLock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the upgrade times out while the other reader holds the lock and keeps
+	// the shared lock
+	r1.Configure(WithTimeout(50 * time.Millisecond))
+	if err := r1.Upgrade(); err != ErrLockTimeout {
+		t.Fatalf("expected upgrade to time out, got %v", err)
+	}
+	if err := New(file.Name()).TryLock(); err != ErrLockLocked {
+		t.Fatalf("expected shared lock to be kept, got %v", err)
+	}
+
+	// a concurrent upgrade within the process is a deadlock
+	r1.Configure(WithTimeout(0))
+	done := make(chan error, 1)
+	go func() {
+		done <- r1.Upgrade()
+	}()
+	for {
+		upgrading.Lock()
+		started := upgrading.paths[file.Name()]
+		upgrading.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if err := r2.Upgrade(); err != ErrLockDeadlock {
+		t.Fatalf("expected deadlock, got %v", err)
+	}
+	r2.Unlock()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := r2.TryRLock(); err != ErrLockLocked {
+		t.Fatalf("expected upgraded lock to be exclusive, got %v", err)
+	}
+
+	if err := r1.Downgrade(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r2.TryRLock(); err != nil {
+		t.Fatalf("expected downgraded lock to be shared, got %v", err)
+	}
+	if err := New(file.Name()).TryLock(); err != ErrLockLocked {
+		t.Fatalf("expected downgraded lock to exclude writers, got %v", err)
+	}
+	r1.Unlock()
+	r2.Unlock()
+}
+
+func TestConvertUnlockRace(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	// the release of a lock removing its file reads its mode
+	l := New(file.Name(), WithRemoveOnUnlock())
+	for i := 0; i < 100; i++ {
+		if err := l.RLock(); err != nil {
+			t.Fatal(err)
+		}
+		// the conversions either succeed or find the lock released
+		converted := make(chan error, 1)
+		go func() {
+			err := l.Upgrade()
+			if err == nil {
+				err = l.Downgrade()
+			}
+			converted <- err
+		}()
+		// the release is swept over the conversions
+		time.Sleep(time.Duration(i) * time.Microsecond)
+		if err := l.Unlock(); err != nil {
+			t.Fatal(err)
+		}
+		if err := <-converted; err != nil && !errors.Is(err, ErrNotLocked) {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRLockProcesses(t *testing.T) {
+	if path := os.Getenv(rwChildEnv); path != "" {
+		rwChild(path)
+		return
+	}
+
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRLockProcesses$")
+	cmd.Env = append(os.Environ(), rwChildEnv+"="+file.Name())
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	out := bufio.NewReader(stdout)
+	line, err := out.ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != "shared" {
+		t.Fatalf("child didn't acquire the lock: %q %v", line, err)
+	}
+
+	r, w := New(file.Name()), New(file.Name())
+	if err := r.TryRLock(); err != nil {
+		t.Fatalf("expected lock to be shared with child, got %v", err)
+	}
+	if err := w.TryLock(); err != ErrLockLocked {
+		t.Fatalf("expected writer to be excluded, got %v", err)
+	}
+	// the child upgrades once the lock isn't shared anymore
+	fmt.Fprintln(stdin, "upgrade")
+	time.Sleep(50 * time.Millisecond)
+	r.Unlock()
+	line, err = out.ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != "exclusive" {
+		t.Fatalf("child didn't upgrade the lock: %q %v", line, err)
+	}
+	if err := r.TryRLock(); err != ErrLockLocked {
+		t.Fatalf("expected reader to be excluded, got %v", err)
+	}
+
+	stdin.Close()
+	if err := cmd.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.TryLock(); err != nil {
+		t.Fatal(err)
+	}
+	w.Unlock()
+}
+
+// rwChild holds the lock at path shared, upgrades it on request and exits once
+// stdin is closed.
+func rwChild(path string) {
+	l := New(path, WithRetryInterval(10*time.Millisecond))
+	if err := l.RLock(); err != nil {
+		fmt.Fprintf(os.Stderr, "rlock failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("shared")
+
+	in := bufio.NewScanner(os.Stdin)
+	for in.Scan() {
+		if err := l.Upgrade(); err != nil {
+			fmt.Fprintf(os.Stderr, "upgrade failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("exclusive")
+	}
+	if err := l.Unlock(); err != nil {
+		fmt.Fprintf(os.Stderr, "unlock failed: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
@@ -0,0 +1,253 @@
+package lock
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/peertechde/lib/clock"
+)
+
+// Scheduler acquires the locks of many Lockers with a single goroutine.
+// Instead of a goroutine sleeping per waiting Locker, the pending acquisitions
+// are retried in order of their next attempt, as scheduled by the Backoff of
+// their Locker and at most at the rate of the Scheduler.
+type Scheduler struct {
+	clock clock.Clock
+	every time.Duration
+
+	mu      sync.Mutex
+	pending requests
+	last    time.Time
+	closed  bool
+	wake    chan struct{}
+}
+
+// Acquisition is the result of an acquisition by a Scheduler.
+type Acquisition struct {
+	Guard *Guard
+	Err   error
+}
+
+// NewScheduler returns a Scheduler making at most rate acquisition attempts
+// per second. A zero rate doesn't bound the attempts.
+func NewScheduler(rate int) *Scheduler {
+	s := &Scheduler{
+		clock: defaultClock(),
+		wake:  make(chan struct{}, 1),
+	}
+	if rate > 0 {
+		s.every = time.Second / time.Duration(rate)
+	}
+	go s.run()
+	return s
+}
+
+// Acquire schedules the acquisition of the lock of l. The returned channel
+// receives the Guard once the lock is acquired, or the error if it couldn't
+// be acquired within the timeout of l or before ctx was done. The file stays
+// open while the acquisition is pending, a cancellation of ctx is noticed at
+// the next attempt.
+func (s *Scheduler) Acquire(ctx context.Context, l *Locker) <-chan Acquisition {
+	result := make(chan Acquisition, 1)
+
+	if l.linked() {
+		result <- Acquisition{Err: errLinkLock}
+		return result
+	}
+	h, err := l.open()
+	if err != nil {
+		result <- Acquisition{Err: err}
+		return result
+	}
+	l.mu.Lock()
+	timeout, clk, hooks := l.timeout, l.clock, l.hooks
+	l.mu.Unlock()
+
+	hooks.OnAcquireStart(l.path)
+	now := s.clock.Now()
+	r := &request{ctx: ctx, locker: l, held: h, start: clk.Now(), next: now, result: result}
+	if timeout > 0 {
+		r.deadline = now.Add(timeout)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		h.fs.Close(h.file)
+		result <- Acquisition{Err: ErrSchedulerClosed}
+		return result
+	}
+	heap.Push(&s.pending, r)
+	s.notify()
+	return result
+}
+
+// Pending returns the number of pending acquisitions.
+func (s *Scheduler) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.pending)
+}
+
+// Close stops the Scheduler, the pending acquisitions fail with
+// ErrSchedulerClosed.
+func (s *Scheduler) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+	for _, r := range s.pending {
+		r.fail(ErrSchedulerClosed)
+	}
+	s.pending = nil
+	s.notify()
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) run() {
+	for {
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+		if len(s.pending) == 0 {
+			s.mu.Unlock()
+			<-s.wake
+			continue
+		}
+		next := s.pending[0].next
+		if earliest := s.last.Add(s.every); s.every > 0 && next.Before(earliest) {
+			next = earliest
+		}
+		now := s.clock.Now()
+		if wait := next.Sub(now); wait > 0 {
+			s.mu.Unlock()
+			timer := s.clock.NewTimer(wait)
+			select {
+			case <-timer.C():
+			case <-s.wake:
+				timer.Stop()
+			}
+			continue
+		}
+		r := heap.Pop(&s.pending).(*request)
+		s.last = now
+		s.mu.Unlock()
+
+		if s.attempt(r, now) {
+			s.mu.Lock()
+			if s.closed {
+				r.fail(ErrSchedulerClosed)
+			} else {
+				heap.Push(&s.pending, r)
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// attempt tries to acquire the lock of r once and reports whether r has to be
+// retried.
+func (s *Scheduler) attempt(r *request, now time.Time) bool {
+	if err := r.ctx.Err(); err != nil {
+		r.fail(cancelled(err))
+		return false
+	}
+	err := setLock(r.held.fs, r.held.file, modeExclusive, region{})
+	if err == ErrLockLocked {
+		err = r.locker.takeOver(r.held, modeExclusive, region{})
+	}
+	switch {
+	case err == nil && (r.held.remove || r.held.ttl > 0):
+		current, err := r.held.current()
+		if err != nil {
+			r.fail(err)
+			return false
+		}
+		if !current {
+			// the file was removed by its previous holder or replaced by a
+			// waiter taking over, lock the new one
+			r.held.fs.Close(r.held.file)
+			h, err := r.locker.open()
+			if err != nil {
+				r.result <- Acquisition{Err: err}
+				return false
+			}
+			r.held, r.next = h, now
+			return true
+		}
+		fallthrough
+	case err == nil:
+		if err := r.locker.acquired(r.held, r.start); err != nil {
+			r.fail(err)
+			return false
+		}
+		r.result <- Acquisition{Guard: r.locker.guard(r.held)}
+		return false
+	case err != ErrLockLocked:
+		r.fail(lockFailed(r.held.path, err))
+		return false
+	case !r.deadline.IsZero() && !now.Before(r.deadline):
+		r.fail(ErrLockTimeout)
+		return false
+	}
+	r.locker.mu.Lock()
+	interval, hooks := r.locker.delay(r.attempt), r.locker.hooks
+	r.locker.mu.Unlock()
+	r.attempt++
+	hooks.OnRetry(r.locker.path, r.attempt)
+
+	r.next = now.Add(interval)
+	if !r.deadline.IsZero() && r.next.After(r.deadline) {
+		r.next = r.deadline
+	}
+	return true
+}
+
+type request struct {
+	ctx      context.Context
+	locker   *Locker
+	held     *handle
+	start    time.Time
+	next     time.Time
+	attempt  int
+	deadline time.Time
+	result   chan Acquisition
+}
+
+func (r *request) fail(err error) {
+	r.held.fs.Close(r.held.file)
+	r.result <- Acquisition{Err: err}
+}
+
+// requests is a heap of requests ordered by their next attempt.
+type requests []*request
+
+func (q requests) Len() int           { return len(q) }
+func (q requests) Less(i, j int) bool { return q[i].next.Before(q[j].next) }
+func (q requests) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *requests) Push(x interface{}) {
+	*q = append(*q, x.(*request))
+}
+
+func (q *requests) Pop() interface{} {
+	old := *q
+	r := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return r
+}
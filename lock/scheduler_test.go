@@ -0,0 +1,113 @@
+package lock
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestScheduler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	const shards = 100
+	holders := make([]*Locker, shards)
+	for i := range holders {
+		path := filepath.Join(dir, strconv.Itoa(i))
+		if err := ioutil.WriteFile(path, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+		holders[i] = New(path)
+		if err := holders[i].Lock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewScheduler(0)
+	defer s.Close()
+
+	goroutines := runtime.NumGoroutine()
+	results := make([]<-chan Acquisition, shards)
+	for i := range results {
+		results[i] = s.Acquire(context.Background(), New(holders[i].Path(), WithRetryInterval(5*time.Millisecond)))
+	}
+	if n := runtime.NumGoroutine(); n > goroutines+2 {
+		t.Fatalf("expected pending acquisitions not to start goroutines, got %d more", n-goroutines)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if s.Pending() != shards {
+		t.Fatalf("expected %d pending acquisitions, got %d", shards, s.Pending())
+	}
+
+	for i, holder := range holders {
+		if err := holder.Unlock(); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case a := <-results[i]:
+			if a.Err != nil {
+				t.Fatal(a.Err)
+			}
+			if err := a.Guard.Release(); err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("lock %d wasn't acquired", i)
+		}
+	}
+}
+
+func TestSchedulerFailures(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	holder := New(file.Name())
+	if err := holder.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	defer holder.Unlock()
+
+	s := NewScheduler(1000)
+
+	// timeout
+	l := New(file.Name(), WithRetryInterval(5*time.Millisecond))
+	l.Configure(WithTimeout(20 * time.Millisecond))
+	if a := <-s.Acquire(context.Background(), l); a.Err != ErrLockTimeout {
+		t.Fatalf("expected %v, got %v", ErrLockTimeout, a.Err)
+	}
+
+	// cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	result := s.Acquire(ctx, New(file.Name(), WithRetryInterval(5*time.Millisecond)))
+	cancel()
+	if a := <-result; a.Err == nil || a.Guard != nil {
+		t.Fatalf("expected cancelled acquisition, got %+v", a)
+	}
+
+	// missing file
+	if a := <-s.Acquire(context.Background(), New(file.Name()+"-missing")); a.Err == nil {
+		t.Fatal("expected acquisition of missing file to fail")
+	}
+
+	// close
+	result = s.Acquire(context.Background(), New(file.Name(), WithRetryInterval(5*time.Millisecond)))
+	s.Close()
+	if a := <-result; a.Err != ErrSchedulerClosed {
+		t.Fatalf("expected %v, got %v", ErrSchedulerClosed, a.Err)
+	}
+	if a := <-s.Acquire(context.Background(), New(file.Name())); a.Err != ErrSchedulerClosed {
+		t.Fatalf("expected %v, got %v", ErrSchedulerClosed, a.Err)
+	}
+}
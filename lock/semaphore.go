@@ -0,0 +1,124 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Semaphore is a counting semaphore shared across processes, e.g. to limit the
+// number of concurrent imports on a host. Its n slots are the first n bytes of
+// the lock file, each locked by a byte range lock, see LockRange; the slots of
+// a process which exits are freed with its locks. The flock backend can't
+// lock byte ranges.
+type Semaphore struct {
+	path string
+	n    int
+	opts []Option
+	// probe provides the configuration of the Lockers to Acquire
+	probe *Locker
+
+	mu   sync.Mutex
+	held map[int]*Locker
+}
+
+// NewSemaphore returns the Semaphore of n slots in the file at path, locked by
+// Lockers configured by opts. The file is created on acquisition if it
+// doesn't exist.
+func NewSemaphore(path string, n int, opts ...Option) (*Semaphore, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("invalid semaphore size %d: %w", n, os.ErrInvalid)
+	}
+	opts = append([]Option{WithCreate(defaultPerm)}, opts...)
+	return &Semaphore{
+		path:  path,
+		n:     n,
+		opts:  opts,
+		probe: New(path, opts...),
+		held:  make(map[int]*Locker),
+	}, nil
+}
+
+// Path returns the path of the lock file.
+func (s *Semaphore) Path() string {
+	return s.path
+}
+
+// Size returns the number of slots.
+func (s *Semaphore) Size() int {
+	return s.n
+}
+
+// Acquire acquires the first free slot and returns its index. It retries at
+// the retry interval while all slots are in use, until ctx is done or the
+// timeout of the Lockers expired, see WithTimeout.
+func (s *Semaphore) Acquire(ctx context.Context) (int, error) {
+	l := s.probe
+	l.mu.Lock()
+	clk, timeout := l.clock, l.timeout
+	l.mu.Unlock()
+
+	expired, stop := expiry(clk, timeout)
+	defer stop()
+
+	var slot int
+	err := l.retry(ctx, expired, clk, s.path, true, func() error {
+		var err error
+		slot, err = s.acquireSlot()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return slot, nil
+}
+
+// TryAcquire acquires the first free slot like Acquire without blocking. It
+// returns ErrLockLocked if all slots are in use.
+func (s *Semaphore) TryAcquire() (int, error) {
+	return s.acquireSlot()
+}
+
+// acquireSlot tries every slot once, it returns ErrLockLocked if all of them
+// are in use.
+func (s *Semaphore) acquireSlot() (int, error) {
+	for slot := 0; slot < s.n; slot++ {
+		// the slots held by the process exclude its other descriptors as
+		// well, they're skipped to spare the attempt
+		s.mu.Lock()
+		_, held := s.held[slot]
+		s.mu.Unlock()
+		if held {
+			continue
+		}
+		l := New(s.path, s.opts...)
+		err := l.TryLockRange(int64(slot), 1, true)
+		if errors.Is(err, ErrLockLocked) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		s.mu.Lock()
+		s.held[slot] = l
+		s.mu.Unlock()
+		return slot, nil
+	}
+	return 0, ErrLockLocked
+}
+
+// Release releases the slot acquired by Acquire. It returns ErrNotLocked if
+// the slot isn't held.
+func (s *Semaphore) Release(slot int) error {
+	s.mu.Lock()
+	l, ok := s.held[slot]
+	delete(s.held, slot)
+	s.mu.Unlock()
+
+	if !ok {
+		return ErrNotLocked
+	}
+	return l.Unlock()
+}
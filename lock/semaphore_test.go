@@ -0,0 +1,73 @@
+package lock
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSemaphore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "importers.lock")
+
+	if _, err := NewSemaphore(path, 0); err == nil {
+		t.Fatal("expected empty semaphore to be invalid")
+	}
+	s1, err := NewSemaphore(path, 2, WithRetryInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if New(path).Backend() == "flock" {
+		t.Skip("flock can't lock byte ranges")
+	}
+	s2, err := NewSemaphore(path, 2, WithRetryInterval(10*time.Millisecond), WithTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the slots are shared by the semaphores of the file
+	if slot, err := s1.TryAcquire(); err != nil || slot != 0 {
+		t.Fatalf("expected slot 0, got %d %v", slot, err)
+	}
+	if slot, err := s2.TryAcquire(); err != nil || slot != 1 {
+		t.Fatalf("expected slot 1, got %d %v", slot, err)
+	}
+	if _, err := s1.TryAcquire(); err != ErrLockLocked {
+		t.Fatalf("expected %v, got %v", ErrLockLocked, err)
+	}
+	if _, err := s2.Acquire(context.Background()); err != ErrLockTimeout {
+		t.Fatalf("expected %v, got %v", ErrLockTimeout, err)
+	}
+
+	acquired := make(chan error, 1)
+	go func() {
+		slot, err := s1.Acquire(context.Background())
+		if err == nil && slot != 0 {
+			err = ErrLockLocked
+		}
+		acquired <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	if err := s1.Release(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-acquired; err != nil {
+		t.Fatalf("expected released slot to be acquired, got %v", err)
+	}
+	if err := s1.Release(1); err != ErrNotLocked {
+		t.Fatalf("expected %v, got %v", ErrNotLocked, err)
+	}
+	if err := s1.Release(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s2.Release(1); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,185 @@
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// ShmDir is the directory of the shared memory objects opened by OpenShm.
+const ShmDir = "/dev/shm"
+
+// Shm is a lock and a set of counters in memory, backed by a memfd or a file
+// in ShmDir. It allows processes of a host to coordinate without touching
+// persistent storage. The object is addressed by its name in ShmDir or passed
+// by its descriptor, e.g. through ExtraFiles of os/exec or SCM_RIGHTS.
+type Shm struct {
+	file *os.File
+	path string
+	// proc is true if the object is reopened through /proc, see Locker
+	proc bool
+	mem  []byte
+}
+
+// shmSize is the size of the object, a page of counters.
+const shmSize = 4096
+
+// CreateMemfd creates an anonymous Shm with memfd_create(2). name is only used
+// for debugging, the object can only be shared by passing its descriptor.
+func CreateMemfd(name string) (*Shm, error) {
+	p, err := unix.BytePtrFromString(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name: %w", err)
+	}
+	// memfd_create isn't wrapped by the pinned version of x/sys
+	fd, _, errno := unix.Syscall(unix.SYS_MEMFD_CREATE, uintptr(unsafe.Pointer(p)), unix.MFD_CLOEXEC, 0)
+	if errno != 0 {
+		return nil, fmt.Errorf("memfd_create failed: %w", errno)
+	}
+	return newShm(os.NewFile(fd, "memfd:"+name))
+}
+
+// OpenShm opens the Shm called name in ShmDir and creates it if it doesn't
+// exist.
+func OpenShm(name string) (*Shm, error) {
+	if name == "" || strings.ContainsRune(name, '/') {
+		return nil, fmt.Errorf("invalid shared memory name %q", name)
+	}
+	file, err := os.OpenFile(filepath.Join(ShmDir, name), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open failed: %w", diagnose("open", filepath.Join(ShmDir, name), err))
+	}
+	return newShm(file)
+}
+
+// ShmFromFd returns the Shm of the descriptor fd, e.g. a memfd inherited from
+// the parent process. The descriptor is owned by the Shm afterwards.
+func ShmFromFd(fd uintptr) (*Shm, error) {
+	return newShm(os.NewFile(fd, "shm"))
+}
+
+func newShm(file *os.File) (*Shm, error) {
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("stat failed: %w", err)
+	}
+	if !fi.Mode().IsRegular() {
+		file.Close()
+		return nil, errors.New("not a shared memory object")
+	}
+	// grow only, the object may be in use already
+	if fi.Size() < shmSize {
+		if err := file.Truncate(shmSize); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("truncate failed: %w", err)
+		}
+	}
+	mem, err := unix.Mmap(int(file.Fd()), 0, shmSize, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("mmap failed: %w", err)
+	}
+	path, proc := file.Name(), !filepath.IsAbs(file.Name())
+	if proc {
+		// every open of the descriptor through procfs creates another open
+		// file description, which is required to exclude other Lockers
+		path = "/proc/self/fd/" + strconv.Itoa(int(file.Fd()))
+	}
+	return &Shm{file: file, path: path, proc: proc, mem: mem}, nil
+}
+
+// File returns the file of the object, to pass its descriptor to other
+// processes.
+func (s *Shm) File() *os.File {
+	return s.file
+}
+
+// Locker returns a new Locker of the object. Lockers of the same object
+// exclude each other, within the process and across processes. Objects
+// without a name in ShmDir, e.g. memfds, are reopened through /proc; in
+// restricted mode their Lockers fail, see SetRestricted.
+func (s *Shm) Locker() *Locker {
+	if !s.proc {
+		return New(s.path)
+	}
+	return New(s.path, WithFileSystem(procFileSystem{OSFileSystem}))
+}
+
+// procFileSystem is the FileSystem of the Lockers reopening descriptors
+// through /proc, which isn't read in restricted mode.
+type procFileSystem struct {
+	FileSystem
+}
+
+func (fs procFileSystem) Stat(name string) (os.FileInfo, error) {
+	if Restricted() {
+		return nil, errProcRestricted
+	}
+	fi, err := fs.FileSystem.Stat(name)
+	if err != nil {
+		restrict("stat "+name, err)
+	}
+	return fi, err
+}
+
+func (fs procFileSystem) OpenFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	if Restricted() {
+		return nil, errProcRestricted
+	}
+	file, err := fs.FileSystem.OpenFile(name, flag, perm)
+	if err != nil {
+		restrict("open "+name, err)
+	}
+	return file, err
+}
+
+// Counter returns the counter at index, which is shared by all users of the
+// object. There are 512 counters, all start at zero.
+func (s *Shm) Counter(index int) (*Counter, error) {
+	if index < 0 || index >= shmSize/8 {
+		return nil, fmt.Errorf("counter index %d out of range", index)
+	}
+	return &Counter{shm: s, value: (*int64)(unsafe.Pointer(&s.mem[index*8]))}, nil
+}
+
+// Close unmaps and closes the object. Its Counters and the Lockers of a memfd
+// mustn't be used afterwards, held locks stay held until they are released.
+func (s *Shm) Close() error {
+	if err := unix.Munmap(s.mem); err != nil {
+		s.file.Close()
+		return fmt.Errorf("munmap failed: %w", err)
+	}
+	return s.file.Close()
+}
+
+// Counter is an integer in shared memory, its operations are atomic across
+// processes.
+type Counter struct {
+	// shm keeps the mapping referenced
+	shm   *Shm
+	value *int64
+}
+
+// Add adds delta to the counter and returns the new value.
+func (c *Counter) Add(delta int64) int64 {
+	return atomic.AddInt64(c.value, delta)
+}
+
+// Load returns the value of the counter.
+func (c *Counter) Load() int64 {
+	return atomic.LoadInt64(c.value)
+}
+
+// CompareAndSwap sets the counter to new if it's old and reports whether it
+// did.
+func (c *Counter) CompareAndSwap(old, new int64) bool {
+	return atomic.CompareAndSwapInt64(c.value, old, new)
+}
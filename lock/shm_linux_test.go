@@ -0,0 +1,142 @@
+package lock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestShm(t *testing.T) {
+	s, err := CreateMemfd("lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// a second mapping, like in a process the descriptor was passed to
+	fd, err := unix.Dup(int(s.File().Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ShmFromFd(uintptr(fd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	l1, l2 := s.Locker(), other.Locker()
+	if err := l1.TryLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l2.TryLock(); err != ErrLockLocked {
+		t.Fatalf("expected %v, got %v", ErrLockLocked, err)
+	}
+	if err := l1.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l2.TryLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l2.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	c1, err := s.Counter(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c2, err := other.Counter(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(c *Counter) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				c.Add(1)
+			}
+		}([]*Counter{c1, c2}[i%2])
+	}
+	wg.Wait()
+	if c1.Load() != 10000 || c2.Load() != 10000 {
+		t.Fatalf("expected 10000, got %d and %d", c1.Load(), c2.Load())
+	}
+	if !c2.CompareAndSwap(10000, 0) || c1.Load() != 0 {
+		t.Fatalf("expected shared swap, got %d", c1.Load())
+	}
+	if _, err := s.Counter(512); err == nil {
+		t.Fatal("expected out of range counter to fail")
+	}
+}
+
+func TestShmRestricted(t *testing.T) {
+	s, err := CreateMemfd("lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// the memfd can't be reopened without /proc
+	SetRestricted(true)
+	defer SetRestricted(false)
+	l := s.Locker()
+	if err := l.TryLock(); !errors.Is(err, errProcRestricted) {
+		t.Fatalf("expected %v, got %v", errProcRestricted, err)
+	}
+	SetRestricted(false)
+	if err := l.TryLock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenShm(t *testing.T) {
+	if _, err := os.Stat(ShmDir); err != nil {
+		t.Skip(err)
+	}
+	name := fmt.Sprintf("lock-test-%d", os.Getpid())
+	defer os.Remove(filepath.Join(ShmDir, name))
+
+	s1, err := OpenShm(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s1.Close()
+	s2, err := OpenShm(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s2.Close()
+
+	if s1.Locker().Path() != filepath.Join(ShmDir, name) {
+		t.Fatalf("unexpected path %q", s1.Locker().Path())
+	}
+	l := s1.Locker()
+	if err := l.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s2.Locker().TryLock(); err != ErrLockLocked {
+		t.Fatalf("expected %v, got %v", ErrLockLocked, err)
+	}
+	l.Unlock()
+
+	c1, _ := s1.Counter(0)
+	c2, _ := s2.Counter(0)
+	c1.Add(5)
+	if c2.Load() != 5 {
+		t.Fatalf("expected 5, got %d", c2.Load())
+	}
+
+	if _, err := OpenShm("a/b"); err == nil {
+		t.Fatal("expected name with slash to fail")
+	}
+}
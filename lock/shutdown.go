@@ -0,0 +1,94 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// held tracks every lock held by the process for OnShutdown.
+var held = struct {
+	sync.Mutex
+	seq      uint64
+	releases map[uint64]heldLock
+}{
+	releases: make(map[uint64]heldLock),
+}
+
+type heldLock struct {
+	path    string
+	release func() error
+}
+
+// registerHeld tracks a held lock and returns the id to untrack it.
+func registerHeld(path string, release func() error) uint64 {
+	held.Lock()
+	defer held.Unlock()
+
+	held.seq++
+	held.releases[held.seq] = heldLock{path: path, release: release}
+	return held.seq
+}
+
+func unregisterHeld(id uint64) {
+	held.Lock()
+	defer held.Unlock()
+
+	delete(held.releases, id)
+}
+
+// OnShutdown releases every lock held by the process, through Lockers as well
+// as Guards, in reverse order of acquisition. It returns once all locks are
+// released or ctx is done, whichever happens first. Locks must not be used
+// concurrently with OnShutdown.
+func OnShutdown(ctx context.Context) error {
+	held.Lock()
+	ids := make([]uint64, 0, len(held.releases))
+	for id := range held.releases {
+		ids = append(ids, id)
+	}
+	locks := held.releases
+	held.Unlock()
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+	var failed []string
+	for i, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("shutdown cancelled with %d locks held: %w", len(ids)-i, err)
+		}
+		held.Lock()
+		lock, ok := locks[id]
+		held.Unlock()
+		if !ok {
+			// released concurrently
+			continue
+		}
+		if err := lock.release(); err != nil {
+			failed = append(failed, lock.path+": "+err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("release failed: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// heldPaths returns the sorted, distinct paths of the locks held by the
+// process.
+func heldPaths() []string {
+	held.Lock()
+	defer held.Unlock()
+
+	seen := make(map[string]bool, len(held.releases))
+	paths := make([]string, 0, len(held.releases))
+	for _, lock := range held.releases {
+		if !seen[lock.path] {
+			seen[lock.path] = true
+			paths = append(paths, lock.path)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
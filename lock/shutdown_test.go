@@ -0,0 +1,65 @@
+package lock
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestOnShutdown(t *testing.T) {
+	var paths []string
+	for i := 0; i < 3; i++ {
+		file, err := ioutil.TempFile("", "lock-test")
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+		defer os.Remove(file.Name())
+		paths = append(paths, file.Name())
+	}
+
+	first := New(paths[0])
+	if err := first.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	second, err := New(paths[1]).Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	released := New(paths[2])
+	if err := released.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := released.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := OnShutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if first.File() != nil || second.File() != nil {
+		t.Fatal("expected all locks to be released")
+	}
+	for _, path := range paths {
+		l := New(path)
+		if err := l.TryLock(); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		l.Unlock()
+	}
+
+	// cancelled shutdown
+	if err := first.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	defer first.Unlock()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := OnShutdown(ctx); err == nil {
+		t.Fatal("expected cancelled shutdown to fail")
+	}
+	if first.File() == nil {
+		t.Fatal("expected lock to be held after cancelled shutdown")
+	}
+}
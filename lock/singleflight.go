@@ -0,0 +1,64 @@
+package lock
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	libioutil "github.com/peertechde/lib/ioutil"
+)
+
+// Flight deduplicates work across the processes of a host, see Singleflight.
+type Flight struct {
+	lockPath   string
+	resultPath string
+}
+
+// Singleflight returns a Flight for key. Of the processes concurrently calling
+// Do for the same key, one performs the work while the others wait and read
+// the result it published. key is the path prefix of the files coordinating
+// the flight, key.lock and key.result.
+func Singleflight(key string) *Flight {
+	return &Flight{lockPath: key + ".lock", resultPath: key + ".result"}
+}
+
+// Do calls fn unless another process is already performing the flight, in
+// which case it waits for and returns the result of the other process; shared
+// reports which happened. The result of fn is published atomically. If the
+// performer fails, its error isn't shared, one of the waiting processes
+// performs the flight instead.
+func (f *Flight) Do(ctx context.Context, fn func(ctx context.Context) ([]byte, error)) (result []byte, shared bool, err error) {
+	before, err := os.Stat(f.resultPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, false, fmt.Errorf("stat result failed: %w", err)
+	}
+	g, err := New(f.lockPath, WithCreate(0660)).Acquire(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer g.Release()
+
+	// the result is replaced by a rename, a new file means another process
+	// performed the flight since it started
+	after, err := os.Stat(f.resultPath)
+	if err == nil && (before == nil || !os.SameFile(before, after)) {
+		result, err := ioutil.ReadFile(f.resultPath)
+		if err != nil {
+			return nil, false, fmt.Errorf("read result failed: %w", err)
+		}
+		return result, true, nil
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return nil, false, fmt.Errorf("stat result failed: %w", err)
+	}
+
+	result, err = fn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := libioutil.AtomicWriteFile(f.resultPath, result, 0640); err != nil {
+		return nil, false, fmt.Errorf("publish result failed: %w", err)
+	}
+	return result, false, nil
+}
@@ -0,0 +1,95 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSingleflight(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	key := filepath.Join(dir, "fill")
+
+	var calls, shared int32
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			<-start
+			result, ok, err := Singleflight(key).Do(context.Background(), func(ctx context.Context) ([]byte, error) {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(300 * time.Millisecond)
+				return []byte("result"), nil
+			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if string(result) != "result" {
+				t.Errorf("unexpected result %q", result)
+			}
+			if ok {
+				atomic.AddInt32(&shared, 1)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+	if calls != 1 || shared != 4 {
+		t.Fatalf("expected 1 call and 4 shared results, got %d calls and %d shared", calls, shared)
+	}
+
+	// a later flight performs the work again
+	result, ok, err := Singleflight(key).Do(context.Background(), func(ctx context.Context) ([]byte, error) {
+		return []byte("again"), nil
+	})
+	if err != nil || ok || string(result) != "again" {
+		t.Fatalf("unexpected result %q, %t, %v", result, ok, err)
+	}
+}
+
+func TestSingleflightFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	key := filepath.Join(dir, "fill")
+
+	failed := errors.New("failed")
+	performing := make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := Singleflight(key).Do(context.Background(), func(ctx context.Context) ([]byte, error) {
+			close(performing)
+			time.Sleep(100 * time.Millisecond)
+			return nil, failed
+		})
+		done <- err
+	}()
+	<-performing
+
+	// the waiter takes over
+	result, ok, err := Singleflight(key).Do(context.Background(), func(ctx context.Context) ([]byte, error) {
+		return []byte("result"), nil
+	})
+	if err != nil || ok || string(result) != "result" {
+		t.Fatalf("unexpected result %q, %t, %v", result, ok, err)
+	}
+	if err := <-done; err != failed {
+		t.Fatalf("expected %v, got %v", failed, err)
+	}
+}
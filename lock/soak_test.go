@@ -0,0 +1,247 @@
+//go:build soak && linux
+// +build soak,linux
+
+package lock
+
+// The soak test runs workers in separate processes which repeatedly acquire
+// the lock, update shared state and release the lock while the test randomly
+// kills them with SIGKILL. It verifies that the state is never torn, that the
+// lock is never held by two live workers and that the lock isn't lost
+// permanently by a worker dying while holding it.
+//
+//	go test -tags soak -run TestSoak ./lock -soak.duration=4h
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	libioutil "github.com/peertechde/lib/ioutil"
+)
+
+const (
+	soakWorkerEnv = "LOCK_SOAK_DIR"
+
+	// soakViolation is the exit code of a worker which detected a violated
+	// invariant
+	soakViolation = 3
+)
+
+var (
+	soakDuration = flag.Duration("soak.duration", 30*time.Second, "duration of the soak test")
+	soakWorkers  = flag.Int("soak.workers", 8, "number of concurrent worker processes")
+	soakKill     = flag.Duration("soak.kill", 100*time.Millisecond, "mean interval between worker kills")
+	soakStall    = flag.Duration("soak.stall", 10*time.Second, "maximum time without progress")
+)
+
+func TestSoak(t *testing.T) {
+	if dir := os.Getenv(soakWorkerEnv); dir != "" {
+		soakWorker(dir)
+		return
+	}
+
+	dir, err := ioutil.TempDir("", "lock-soak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "lock"), nil, 0660); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSoakState(dir, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	exited := make(chan *exec.Cmd)
+	workers := make(map[*exec.Cmd]bool)
+	kill := func(cmd *exec.Cmd) {
+		// the waiters of a killed holder may acquire the lock before the
+		// holder is a zombie, it's marked as killed for them beforehand
+		ioutil.WriteFile(soakKilled(dir, cmd.Process.Pid), nil, 0660)
+		cmd.Process.Kill()
+	}
+	defer func() {
+		for cmd := range workers {
+			kill(cmd)
+		}
+		for len(workers) > 0 {
+			delete(workers, <-exited)
+		}
+	}()
+	spawn := func() {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestSoak$")
+		cmd.Env = append(os.Environ(), soakWorkerEnv+"="+dir)
+		cmd.Stderr = os.Stderr
+		if err := cmd.Start(); err != nil {
+			t.Fatal(err)
+		}
+		workers[cmd] = true
+		go func() {
+			cmd.Wait()
+			exited <- cmd
+		}()
+	}
+	for i := 0; i < *soakWorkers; i++ {
+		spawn()
+	}
+
+	var (
+		kills    int
+		progress = time.Now()
+		last     int64
+		deadline = time.After(*soakDuration)
+		check    = time.NewTicker(100 * time.Millisecond)
+	)
+	defer check.Stop()
+
+loop:
+	for {
+		killTimer := time.After(time.Duration(rand.Int63n(2 * int64(*soakKill))))
+		select {
+		case <-deadline:
+			break loop
+		case <-killTimer:
+			for cmd := range workers {
+				kill(cmd)
+				kills++
+				break
+			}
+		case cmd := <-exited:
+			delete(workers, cmd)
+			status := cmd.ProcessState.Sys().(syscall.WaitStatus)
+			if !status.Signaled() {
+				t.Fatalf("worker exited unexpectedly with status %d", status.ExitStatus())
+			}
+			spawn()
+		case <-check.C:
+			counter, err := readSoakState(dir)
+			if err != nil {
+				t.Fatalf("torn state: %v", err)
+			}
+			if counter < last {
+				t.Fatalf("counter went backwards from %d to %d", last, counter)
+			}
+			if counter > last {
+				last, progress = counter, time.Now()
+			}
+			if time.Since(progress) > *soakStall {
+				t.Fatalf("no progress for %s, lock is lost", *soakStall)
+			}
+		}
+	}
+
+	for cmd := range workers {
+		kill(cmd)
+	}
+	for len(workers) > 0 {
+		delete(workers, <-exited)
+	}
+
+	// the lock must be available once all workers are gone
+	lock := New(filepath.Join(dir, "lock"))
+	if err := lock.TryLock(); err != nil {
+		t.Fatalf("lock isn't available after all workers exited: %v", err)
+	}
+	defer lock.Unlock()
+	counter, err := readSoakState(dir)
+	if err != nil {
+		t.Fatalf("torn state: %v", err)
+	}
+	t.Logf("%d updates, %d workers killed", counter, kills)
+}
+
+// soakWorker updates the state in dir until it's killed.
+func soakWorker(dir string) {
+	violation := func(format string, args ...interface{}) {
+		fmt.Fprintf(os.Stderr, "soak worker %d: "+format+"\n", append([]interface{}{os.Getpid()}, args...)...)
+		os.Exit(soakViolation)
+	}
+	lock := New(filepath.Join(dir, "lock"), WithRetryInterval(time.Millisecond))
+	holder := filepath.Join(dir, "holder")
+	pid := []byte(strconv.Itoa(os.Getpid()))
+	for {
+		if err := lock.Lock(); err != nil {
+			violation("lock failed: %v", err)
+		}
+
+		// a previous holder may have been killed inside the critical section,
+		// but it must not be alive
+		if data, err := ioutil.ReadFile(holder); err == nil && !bytes.Equal(data, pid) {
+			if other, err := strconv.Atoi(string(data)); err == nil && soakAlive(dir, other) {
+				violation("lock is held by live worker %d as well", other)
+			}
+		}
+		if err := ioutil.WriteFile(holder, pid, 0660); err != nil {
+			violation("write holder failed: %v", err)
+		}
+
+		counter, err := readSoakState(dir)
+		if err != nil {
+			violation("torn state: %v", err)
+		}
+		time.Sleep(time.Duration(rand.Int63n(int64(time.Millisecond))))
+		if err := writeSoakState(dir, counter+1); err != nil {
+			violation("write state failed: %v", err)
+		}
+
+		if err := os.Remove(holder); err != nil {
+			violation("remove holder failed: %v", err)
+		}
+		if err := lock.Unlock(); err != nil {
+			violation("unlock failed: %v", err)
+		}
+	}
+}
+
+// soakAlive reports whether the worker pid is alive. Workers killed by the
+// test are dead although they may not be zombies yet, their locks are released
+// as soon as the kill closes their descriptors.
+func soakAlive(dir string, pid int) bool {
+	if _, err := os.Stat(soakKilled(dir, pid)); err == nil {
+		return false
+	}
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return false
+	}
+	// the state follows the parenthesized command name
+	i := bytes.LastIndexByte(data, ')')
+	return i >= 0 && i+2 < len(data) && data[i+2] != 'Z'
+}
+
+// soakKilled returns the path of the file marking the worker pid as killed.
+func soakKilled(dir string, pid int) string {
+	return filepath.Join(dir, "killed."+strconv.Itoa(pid))
+}
+
+// writeSoakState atomically writes counter twice into the state file, a
+// mismatch of both copies indicates a torn write.
+func writeSoakState(dir string, counter int64) error {
+	data := fmt.Sprintf("%d %d", counter, counter)
+	return libioutil.AtomicWriteFile(filepath.Join(dir, "state"), []byte(data), 0660)
+}
+
+func readSoakState(dir string) (int64, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "state"))
+	if err != nil {
+		return 0, err
+	}
+	var a, b int64
+	if _, err := fmt.Sscanf(string(data), "%d %d", &a, &b); err != nil {
+		return 0, fmt.Errorf("malformed state %q: %v", data, err)
+	}
+	if a != b {
+		return 0, fmt.Errorf("mismatching counters %d and %d", a, b)
+	}
+	return a, nil
+}
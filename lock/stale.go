@@ -0,0 +1,104 @@
+package lock
+
+import (
+	"fmt"
+	"os"
+
+	libioutil "github.com/peertechde/lib/ioutil"
+)
+
+// IsStale reports whether the lock file names a holder which is known to be
+// gone: a process of this host which doesn't exist anymore, as recorded by
+// WithOwner. The record outlives its holder if the holder crashed, or if the
+// lock is held on a network file system by a host which crashed. Holders which
+// didn't record themselves and processes of other hosts can't be checked,
+// their locks aren't stale. Leased locks, see WithLease, are stale as well
+// once their lease expired, whether their holder is alive or not.
+//
+// Records which aren't signed with the key of WithOwnerKey, if the Locker has
+// one, are unknown and never stale. Process IDs are reused, a recent holder
+// may be mistaken for a live one.
+func (l *Locker) IsStale() (bool, error) {
+	if l.linked() {
+		return l.linkStale()
+	}
+	h, err := l.open()
+	if err != nil {
+		return false, err
+	}
+	defer h.fs.Close(h.file)
+
+	if expired, err := l.leaseExpired(h.file); err != nil || expired {
+		return expired, err
+	}
+	owner, err := readOwner(h.file, l.key())
+	if err != nil {
+		return false, err
+	}
+	return owner.gone()
+}
+
+// leaseExpired reports whether the lease of the lock file wasn't renewed
+// within the TTL of the Locker, it's false if the Locker has no lease.
+func (l *Locker) leaseExpired(file *os.File) (bool, error) {
+	l.mu.Lock()
+	clk, ttl := l.clock, l.lease
+	l.mu.Unlock()
+
+	if ttl <= 0 {
+		return false, nil
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		return false, fmt.Errorf("stat failed: %w", err)
+	}
+	return clk.Since(fi.ModTime()) >= ttl, nil
+}
+
+// gone reports whether the Owner is known to have exited, which is known only
+// for processes of this host.
+func (o *Owner) gone() (bool, error) {
+	if !o.Known() {
+		return false, nil
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return false, fmt.Errorf("hostname failed: %w", err)
+	}
+	return o.Hostname == hostname && !processAlive(o.PID), nil
+}
+
+// ForceUnlock breaks the lock, whoever holds it. Link locks are broken by
+// removing the lock file, see StrategyLinkLock. The lock file is replaced by
+// an empty file with the same permissions, renamed over it atomically, so that
+// the path refers to a lock file at any time. Subsequent acquisitions lock the
+// new file; the holder of the old one, if any, isn't notified and continues
+// to hold a lock which excludes nobody.
+//
+// ForceUnlock is meant for operators breaking stale locks, see IsStale.
+// Breaking the lock of a live holder lets two holders proceed at once. On
+// Windows, files can't be replaced while they're open.
+func (l *Locker) ForceUnlock() error {
+	if l.linked() {
+		return l.removeLink()
+	}
+	h, err := l.open()
+	if err != nil {
+		return err
+	}
+	fi, err := h.file.Stat()
+	h.fs.Close(h.file)
+	if err != nil {
+		return fmt.Errorf("stat failed: %w", err)
+	}
+	return replaceFile(h.path, fi.Mode().Perm())
+}
+
+// replaceFile replaces the file at path by an empty file with perm, renamed
+// over it atomically.
+func replaceFile(path string, perm os.FileMode) error {
+	if err := libioutil.AtomicWriteFile(path, nil, perm); err != nil {
+		return fmt.Errorf("replace lock file failed: %w", err)
+	}
+	return nil
+}
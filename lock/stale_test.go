@@ -0,0 +1,137 @@
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// deadPID returns the ID of a process which exited.
+func deadPID(t *testing.T) int {
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+func TestIsStale(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	l := New(file.Name(), WithOwner("test"))
+	stale, err := l.IsStale()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stale {
+		t.Fatal("expected unrecorded lock not to be stale")
+	}
+
+	// a live holder
+	if err := l.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := New(file.Name()).IsStale(); err != nil || stale {
+		t.Fatalf("expected live holder not to be stale, got %v %v", stale, err)
+	}
+	l.Unlock()
+
+	// the record left behind by a crashed holder
+	hostname, _ := os.Hostname()
+	owner := &Owner{Version: ownerVersion, PID: deadPID(t), Hostname: hostname, Since: time.Now()}
+	data, _ := json.Marshal(owner)
+	if err := ioutil.WriteFile(file.Name(), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := l.IsStale(); err != nil || !stale {
+		t.Fatalf("expected dead holder to be stale, got %v %v", stale, err)
+	}
+
+	// processes of other hosts can't be checked
+	owner.Hostname = hostname + ".invalid"
+	data, _ = json.Marshal(owner)
+	if err := ioutil.WriteFile(file.Name(), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := l.IsStale(); err != nil || stale {
+		t.Fatalf("expected remote holder not to be stale, got %v %v", stale, err)
+	}
+
+	// the expired lease of a live holder
+	owner.Hostname, owner.PID = hostname, os.Getpid()
+	data, _ = json.Marshal(owner)
+	if err := ioutil.WriteFile(file.Name(), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	ttl := time.Minute
+	if stale, err := New(file.Name(), WithLease(ttl)).IsStale(); err != nil || stale {
+		t.Fatalf("expected valid lease not to be stale, got %v %v", stale, err)
+	}
+	expired := time.Now().Add(-2 * ttl)
+	if err := os.Chtimes(file.Name(), expired, expired); err != nil {
+		t.Fatal(err)
+	}
+	if stale, err := New(file.Name(), WithLease(ttl)).IsStale(); err != nil || !stale {
+		t.Fatalf("expected expired lease to be stale, got %v %v", stale, err)
+	}
+	if stale, err := l.IsStale(); err != nil || stale {
+		t.Fatalf("expected live holder without lease not to be stale, got %v %v", stale, err)
+	}
+}
+
+func TestForceUnlock(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("open files can't be replaced on windows")
+	}
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+	if err := os.Chmod(file.Name(), 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	l1, l2 := New(file.Name(), WithOwner("test")), New(file.Name())
+	if err := l1.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l2.TryLock(); !errors.Is(err, ErrLockLocked) {
+		t.Fatalf("expected %v, got %v", ErrLockLocked, err)
+	}
+	if err := l2.ForceUnlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the path refers to a new, empty file with the same permissions
+	fi, err := os.Stat(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0640 {
+		t.Fatalf("expected mode %v, got %v", os.FileMode(0640), fi.Mode().Perm())
+	}
+	if fi.Size() != 0 {
+		t.Fatalf("expected empty lock file, got %d bytes", fi.Size())
+	}
+	if err := l2.TryLock(); err != nil {
+		t.Fatalf("expected broken lock to be free, got %v", err)
+	}
+	if err := l1.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := l2.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
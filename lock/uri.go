@@ -0,0 +1,78 @@
+package lock
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// OpenURI returns a lock of the backend selected by uri, e.g.
+//
+//	file:///var/lock/app?mode=ofd&timeout=5s
+//	etcd://localhost:2379/locks/app?timeout=5s
+//
+// File URIs select the backend by the "mode" parameter, defaulting to ofd,
+// all other URIs by their scheme. The "retry" and "timeout" parameters set the
+// RetryInterval and Timeout of the Config passed to the backend, remaining
+// parameters are passed as Params. The path of the URI becomes the Path.
+func OpenURI(uri string) (Interface, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("parse uri failed: %w", err)
+	}
+	config, name, err := parseURI(u)
+	if err != nil {
+		return nil, err
+	}
+	return Open(name, config)
+}
+
+func parseURI(u *url.URL) (Config, Backend, error) {
+	config := Config{
+		Path:   u.Path,
+		URL:    u,
+		Params: make(map[string]string),
+	}
+	if u.Scheme == "" {
+		return config, "", fmt.Errorf("uri %q has no scheme", u)
+	}
+	name := Backend(u.Scheme)
+	if u.Scheme == "file" {
+		if u.Host != "" && u.Host != "localhost" {
+			return config, "", fmt.Errorf("file uri %q refers to remote host %q", u, u.Host)
+		}
+		if u.Opaque != "" {
+			// relative path, e.g. file:app.lock
+			config.Path = u.Opaque
+		}
+		name = fileBackend
+	}
+	for key, values := range u.Query() {
+		value := values[len(values)-1]
+		switch key {
+		case "mode":
+			if u.Scheme != "file" {
+				return config, "", fmt.Errorf("parameter mode is only supported by file uris")
+			}
+			name = Backend(value)
+		case "retry":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return config, "", fmt.Errorf("invalid retry parameter: %w", err)
+			}
+			config.RetryInterval = d
+		case "timeout":
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return config, "", fmt.Errorf("invalid timeout parameter: %w", err)
+			}
+			config.Timeout = d
+		default:
+			config.Params[key] = value
+		}
+	}
+	if config.Path == "" {
+		return config, "", fmt.Errorf("uri %q has no path", u)
+	}
+	return config, name, nil
+}
@@ -0,0 +1,97 @@
+package lock
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseURI(t *testing.T) {
+	tests := []struct {
+		uri     string
+		backend Backend
+		config  Config
+		fail    bool
+	}{
+		{
+			uri:     "file:///var/lock/app",
+			backend: BackendOFD,
+			config:  Config{Path: "/var/lock/app"},
+		},
+		{
+			uri:     "file:///var/lock/app?mode=ofd&timeout=5s&retry=10ms",
+			backend: BackendOFD,
+			config:  Config{Path: "/var/lock/app", Timeout: 5 * time.Second, RetryInterval: 10 * time.Millisecond},
+		},
+		{
+			uri:     "file:app.lock",
+			backend: BackendOFD,
+			config:  Config{Path: "app.lock"},
+		},
+		{
+			uri:     "etcd://localhost:2379/locks/app?ttl=10s",
+			backend: "etcd",
+			config:  Config{Path: "/locks/app", Params: map[string]string{"ttl": "10s"}},
+		},
+		{uri: "/var/lock/app", fail: true},
+		{uri: "file://remote/var/lock/app", fail: true},
+		{uri: "file:///var/lock/app?timeout=soon", fail: true},
+		{uri: "redis://localhost?mode=ofd", fail: true},
+	}
+	for _, test := range tests {
+		u, err := url.Parse(test.uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+		config, backend, err := parseURI(u)
+		if test.fail {
+			if err == nil {
+				t.Errorf("%s: expected error", test.uri)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.uri, err)
+			continue
+		}
+		if backend != test.backend {
+			t.Errorf("%s: expected backend %q, got %q", test.uri, test.backend, backend)
+		}
+		if config.Path != test.config.Path || config.Timeout != test.config.Timeout || config.RetryInterval != test.config.RetryInterval {
+			t.Errorf("%s: expected config %+v, got %+v", test.uri, test.config, config)
+		}
+		for key, value := range test.config.Params {
+			if config.Params[key] != value {
+				t.Errorf("%s: expected param %s=%s, got %q", test.uri, key, value, config.Params[key])
+			}
+		}
+	}
+}
+
+func TestOpenURI(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	holder, err := OpenURI("file://" + file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := holder.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	defer holder.Unlock()
+
+	waiter, err := OpenURI("file://" + file.Name() + "?timeout=50ms&retry=10ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := waiter.Lock(); err != ErrLockTimeout {
+		t.Fatalf("expected %v, got %v", ErrLockTimeout, err)
+	}
+}
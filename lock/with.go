@@ -0,0 +1,48 @@
+package lock
+
+import (
+	"context"
+	"sync"
+)
+
+// With locks, calls fn and unlocks after fn returned or panicked. It returns
+// the error of fn, or the error of Unlock if fn succeeded.
+func (l *Locker) With(fn func() error) error {
+	return l.WithContext(context.Background(), fn)
+}
+
+// WithContext runs fn like With, giving up the acquisition once ctx is done,
+// see LockContext. Unlike Do, the Locker itself holds the lock while fn runs.
+func (l *Locker) WithContext(ctx context.Context, fn func() error) (err error) {
+	if err := l.LockContext(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if uerr := l.Unlock(); uerr != nil && err == nil {
+			err = uerr
+		}
+	}()
+	return fn()
+}
+
+// SyncLocker returns the Locker as a sync.Locker, e.g. for a sync.Cond. Its
+// methods panic if Lock or Unlock fail, as a sync.Locker can't report errors.
+func (l *Locker) SyncLocker() sync.Locker {
+	return syncLocker{l}
+}
+
+type syncLocker struct {
+	l *Locker
+}
+
+func (s syncLocker) Lock() {
+	if err := s.l.Lock(); err != nil {
+		panic("lock: lock failed: " + err.Error())
+	}
+}
+
+func (s syncLocker) Unlock() {
+	if err := s.l.Unlock(); err != nil {
+		panic("lock: unlock failed: " + err.Error())
+	}
+}
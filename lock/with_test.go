@@ -0,0 +1,78 @@
+package lock
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestWith(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	l, other := New(file.Name()), New(file.Name())
+	errFn := errors.New("fn failed")
+	err = l.With(func() error {
+		if err := other.TryLock(); err != ErrLockLocked {
+			t.Fatalf("expected lock to be held by With, got %v", err)
+		}
+		return errFn
+	})
+	if err != errFn {
+		t.Fatalf("expected %v, got %v", errFn, err)
+	}
+	if l.File() != nil {
+		t.Fatal("expected lock to be released")
+	}
+
+	// the lock is released on panic
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("expected panic to be propagated")
+			}
+		}()
+		l.With(func() error { panic("fn panicked") })
+	}()
+	if err := other.TryLock(); err != nil {
+		t.Fatalf("expected lock to be released after panic, got %v", err)
+	}
+	other.Unlock()
+}
+
+func TestSyncLocker(t *testing.T) {
+	file, err := ioutil.TempFile("", "lock-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	l := New(file.Name())
+	cond := sync.NewCond(l.SyncLocker())
+	ready := false
+	go func() {
+		cond.L.Lock()
+		ready = true
+		cond.L.Unlock()
+		cond.Signal()
+	}()
+	cond.L.Lock()
+	for !ready {
+		cond.Wait()
+	}
+	cond.L.Unlock()
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected unlock of unlocked Locker to panic")
+		}
+	}()
+	l.SyncLocker().Unlock()
+}